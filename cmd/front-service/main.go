@@ -4,16 +4,24 @@
 package main
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"iltodgeree/api/internal/alerts"
 	"iltodgeree/api/internal/correction"
 	"iltodgeree/api/internal/document"
 	"iltodgeree/api/internal/queries"
 	"iltodgeree/api/internal/sql"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -39,6 +47,205 @@ func CustomRecovery() gin.HandlerFunc {
 	}
 }
 
+// isAdmin reports whether the request carries the admin token from
+// ADMIN_TOKEN, as "Authorization: Bearer <token>". Without ADMIN_TOKEN no
+// request is an admin.
+func isAdmin(c *gin.Context) bool {
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" {
+		return false
+	}
+
+	given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// RequireAdmin rejects requests that do not carry the admin token.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isAdmin(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// reportRange reads the from and to dates (YYYY-MM-DD, both inclusive,
+// default the last 30 days) and the limit (1-1000, default 50) of a search
+// report. It writes a 400 response and returns false when they are invalid.
+func reportRange(c *gin.Context) (time.Time, time.Time, int, bool) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, to := today.AddDate(0, 0, -29), today
+
+	for name, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		if c.Query(name) == "" {
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", c.Query(name))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a YYYY-MM-DD date"})
+			return from, to, 0, false
+		}
+		*t = parsed
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return from, to, 0, false
+	}
+
+	limit := 50
+	if c.Query("limit") != "" {
+		_limit, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || _limit < 1 || _limit > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return from, to, 0, false
+		}
+		limit = _limit
+	}
+
+	return from, to.AddDate(0, 0, 1), limit, true
+}
+
+// logSearch records a user search, replaced in tests.
+var logSearch = sql.LogSearch
+
+// searchHandler serves /api/search. debug=true is only accepted with the
+// admin token. Debug searches and downloads are not logged as user searches.
+func searchHandler(c *gin.Context) {
+	params, err := queries.SearchParamsFromValues(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("debug") != "" {
+		debug, err := strconv.ParseBool(c.Query("debug"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "debug: boolean утгыг хөрвүүлж чадсангүй"})
+			return
+		}
+		if debug && !isAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "debug requires admin access"})
+			return
+		}
+		params.SetDebug(debug)
+	}
+
+	start := time.Now()
+	res, e := queries.SearchV2(params)
+	if *e != nil {
+		panic(e)
+	}
+
+	if !params.Debug() && c.Query("download") == "" {
+		q, filters := params.Normalized()
+		id, err := logSearch(sql.SearchLogEntry{
+			Query:       q,
+			Filters:     filters,
+			HitCount:    res.Hits.TotalHits,
+			ResultFrom:  params.Offset(),
+			ResultCount: len(res.Hits.Hits),
+			LatencyMs:   float64(time.Since(start).Microseconds()) / 1000,
+		})
+		if err != nil {
+			slog.Error("error logging search", "error", err)
+		}
+		res.SearchID = id
+	}
+
+	if c.Query("download") != "" && c.Query("type") == "docx" {
+		files, err := document.FilePathWalkDir(document.TEMPLATE_PATH)
+		if err != nil {
+			panic(err)
+		}
+		document.Process(uuid.New().String(), res.SearchResult, c, files)
+	} else if c.Query("download") != "" && c.Query("type") == "tsv" {
+		document.CSV(uuid.New().String(), res.SearchResult, c)
+	} else {
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+// districtMapHandler serves /api/map/districts, the districts of the
+// province given by province_id.
+func districtMapHandler(c *gin.Context) {
+	if _, err := strconv.Atoi(c.Query("province_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "province_id must be a province ID"})
+		return
+	}
+
+	params, err := queries.SearchParamsFromValues(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := queries.UnitMap(params, c.Query("province_id"))
+	if err != nil {
+		panic(err)
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// runQuality runs the data-quality report from the command line, as
+// "front-service quality [-max check=count,...] [-size n]", and prints it as
+// JSON. It returns the exit code: 1 when a check exceeds its threshold, 2
+// when the setup or the arguments are invalid or the report fails.
+func runQuality(args []string) (code int) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintln(os.Stderr, "quality report failed:", r)
+			code = 2
+		}
+	}()
+
+	flags := flag.NewFlagSet("quality", flag.ContinueOnError)
+	limits := flags.String("max", "", "maximum contract count per check, as check=count pairs separated by commas")
+	size := flags.Int("size", 100, "contract IDs listed per check, 1-10000")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *size < 1 || *size > 10000 {
+		fmt.Fprintln(os.Stderr, "-size must be between 1 and 10000")
+		return 2
+	}
+
+	if err := godotenv.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading .env file:", err)
+		return 2
+	}
+
+	thresholds, err := queries.ParseQualityThresholds(*limits)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	report, err := queries.CheckQuality("", 0, *size)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "quality report failed:", err)
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		fmt.Fprintln(os.Stderr, "quality report failed:", err)
+		return 2
+	}
+
+	exceeded := report.Exceeded(thresholds)
+	for _, e := range exceeded {
+		fmt.Fprintln(os.Stderr, e)
+	}
+	if len(exceeded) > 0 {
+		return 1
+	}
+	return 0
+}
+
 // main initializes and starts the front-end API service.
 // It sets up:
 // - Environment variables from .env file
@@ -46,17 +253,53 @@ func CustomRecovery() gin.HandlerFunc {
 // - Gin web framework with middleware
 // - All HTTP route handlers
 func main() {
+	// The quality command reports its own setup errors with exit code 2,
+	// since 1 means a threshold was exceeded.
+	if len(os.Args) > 1 && os.Args[1] == "quality" {
+		os.Exit(runQuality(os.Args[2:]))
+	}
+
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal("Error loading .env file:", err)
 	}
 
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil && os.Getenv("LOG_LEVEL") != "" {
+		log.Fatal("Error parsing LOG_LEVEL:", err)
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel})))
+
 	document.DOCUMENT_PATH = os.Getenv("DOCUMENT_PATH")
 	document.TEMPLATE_PATH = os.Getenv("TEMPLATE_PATH")
 	document.PUBLIC_URL = os.Getenv("PUBLIC_URL")
 
 	sql.EstablishPgSQL()
 	defer sql.Pgsql.Close()
+	queries.SetUnitNameLoader(sql.GetProvincesAllUnits)
+
+	if err := sql.EnsureSavedSearchTables(); err != nil {
+		log.Fatal("Error creating saved search tables:", err)
+	}
+
+	if err := sql.EnsureSearchAnalyticsTables(); err != nil {
+		log.Fatal("Error creating search analytics tables:", err)
+	}
+
+	if os.Getenv("RELEVANCE_CONFIG") != "" {
+		if err := queries.LoadRelevanceConfig(os.Getenv("RELEVANCE_CONFIG")); err != nil {
+			log.Fatal("Error loading RELEVANCE_CONFIG:", err)
+		}
+	}
+
+	interval := 24 * time.Hour
+	if os.Getenv("SAVED_SEARCH_INTERVAL") != "" {
+		interval, err = time.ParseDuration(os.Getenv("SAVED_SEARCH_INTERVAL"))
+		if err != nil {
+			log.Fatal("Error parsing SAVED_SEARCH_INTERVAL:", err)
+		}
+	}
+	alerts.Start(interval)
 
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
@@ -78,8 +321,33 @@ func main() {
 	})
 
 	r.GET("/api/summary", func(c *gin.Context) {
-		res, err := queries.Aggregations()
+		params, err := queries.SearchParamsFromValues(c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		res, err := queries.Aggregations(params)
+
+		if err != nil {
+			panic(err)
+		}
+
+		c.JSON(http.StatusOK, res)
+	})
+
+	r.GET("/api/stats/timeseries", func(c *gin.Context) {
+		params, err := queries.SearchParamsFromValues(c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
+		res, err := queries.Timeseries(params, c.DefaultQuery("field", "signature_date"), c.DefaultQuery("interval", "month"), c.Query("split"))
+		if errors.Is(err, queries.ErrInvalidStatsParameter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			panic(err)
 		}
@@ -87,6 +355,46 @@ func main() {
 		c.JSON(http.StatusOK, res)
 	})
 
+	r.GET("/api/stats/pivot", func(c *gin.Context) {
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "csv" && format != "xlsx" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv or xlsx"})
+			return
+		}
+
+		params, err := queries.SearchParamsFromValues(c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		res, err := queries.Pivot(params, c.Query("rows"), c.Query("cols"))
+		if errors.Is(err, queries.ErrInvalidStatsParameter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		if format == "json" {
+			c.JSON(http.StatusOK, res)
+			return
+		}
+
+		export, contentType := document.TableCSV, "text/csv; charset=utf-8"
+		if format == "xlsx" {
+			export, contentType = document.TableXLSX, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		}
+		body, err := export(res.Table())
+		if err != nil {
+			panic(err)
+		}
+
+		c.Header("Content-Disposition", "attachment; filename=pivot-"+res.RowDimension+"-"+res.ColDimension+"."+format)
+		c.Data(http.StatusOK, contentType, body)
+	})
+
 	r.GET("/api/summary/year/province/:id", func(c *gin.Context) {
 		pId, err := strconv.Atoi(c.Param("id"))
 
@@ -127,62 +435,239 @@ func main() {
 		c.JSON(http.StatusOK, provinces)
 	})
 
-	r.GET("/api/search", func(c *gin.Context) {
-		params := queries.NewSearchParams(
-			c.Query("q"),
-			c.Query("year"),
-			c.Query("contract_type"),
-			c.Query("resource"),
-			c.Query("company"),
-			c.Query("government"),
-			c.Query("document_type"),
-		)
+	r.GET("/api/map/provinces", func(c *gin.Context) {
+		params, err := queries.SearchParamsFromValues(c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		res, err := queries.UnitMap(params, "")
+		if err != nil {
+			panic(err)
+		}
+
+		c.JSON(http.StatusOK, res)
+	})
+
+	r.GET("/api/map/districts", districtMapHandler)
 
-		if c.Query("province") != "" {
-			params.SetProvince(c.Query("province"))
+	r.GET("/api/graph", func(c *gin.Context) {
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "gexf" && format != "graphml" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, gexf or graphml"})
+			return
 		}
 
-		if c.Query("district") != "" {
-			params.SetDistrict(c.Query("district"))
+		nodes := 50
+		if c.Query("nodes") != "" {
+			_nodes, err := strconv.Atoi(c.Query("nodes"))
+			if err != nil || _nodes < 1 || _nodes > 200 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "nodes must be between 1 and 200"})
+				return
+			}
+			nodes = _nodes
 		}
 
-		if c.Query("annotation_category") != "" {
-			params.SetAnnotationCategories(c.Query("annotation_category"))
+		params, err := queries.SearchParamsFromValues(c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		if c.Query("annotated") != "" {
-			ann, err := strconv.ParseBool(c.Query("annotated"))
-			if err != nil {
-				log.Println("boolean утгыг хөрвүүлж чадсангүй.")
-				panic(err)
+		graph, err := queries.ContractGraph(params, nodes)
+		if err != nil {
+			panic(err)
+		}
+
+		if format == "json" {
+			c.JSON(http.StatusOK, graph)
+			return
+		}
+
+		export, contentType := graph.GEXF, "application/gexf+xml"
+		if format == "graphml" {
+			export, contentType = graph.GraphML, "application/graphml+xml"
+		}
+		body, err := export()
+		if err != nil {
+			panic(err)
+		}
+
+		c.Header("Content-Disposition", "attachment; filename=contracts."+format)
+		c.Data(http.StatusOK, contentType, body)
+	})
+
+	r.GET("/api/search", searchHandler)
+
+	r.POST("/api/search/clicks", func(c *gin.Context) {
+		var data struct {
+			SearchID   int64  `json:"search_id"`
+			ContractID string `json:"contract_id"`
+			Position   int    `json:"position"`
+		}
+
+		if err := c.BindJSON(&data); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if data.SearchID < 1 || data.ContractID == "" || data.Position < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "search_id, contract_id and a position from 1 are required"})
+			return
+		}
+
+		if err := sql.LogSearchClick(data.SearchID, data.ContractID, data.Position); err != nil {
+			if errors.Is(err, sql.ErrSearchNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
 			}
-			params.SetAnnotated(ann)
+			panic(err)
 		}
 
-		params.SetSize(c.Query("size"))
-		params.SetFrom(c.Query("from"))
+		c.JSON(http.StatusOK, nil)
+	})
 
-		params.SetSortBy(c.Query("sort_by"))
-		params.SetOrder(c.Query("is_asc"))
+	admin := r.Group("/api/admin/search", RequireAdmin())
 
-		res, err := queries.SearchV2(params)
-		if *err != nil {
+	admin.GET("/top-queries", func(c *gin.Context) {
+		from, to, limit, ok := reportRange(c)
+		if !ok {
+			return
+		}
+
+		res, err := sql.GetTopQueries(from, to, limit, false)
+		if err != nil {
 			panic(err)
 		}
 
-		if c.Query("download") != "" && c.Query("type") == "docx" {
-			files, err := document.FilePathWalkDir(document.TEMPLATE_PATH)
-			if err != nil {
-				panic(err)
+		c.JSON(http.StatusOK, res)
+	})
+
+	admin.GET("/zero-results", func(c *gin.Context) {
+		from, to, limit, ok := reportRange(c)
+		if !ok {
+			return
+		}
+
+		res, err := sql.GetTopQueries(from, to, limit, true)
+		if err != nil {
+			panic(err)
+		}
+
+		c.JSON(http.StatusOK, res)
+	})
+
+	admin.GET("/filters", func(c *gin.Context) {
+		from, to, limit, ok := reportRange(c)
+		if !ok {
+			return
+		}
+
+		res, err := sql.GetFilterUsage(from, to, limit)
+		if err != nil {
+			panic(err)
+		}
+
+		c.JSON(http.StatusOK, res)
+	})
+
+	admin.GET("/ctr", func(c *gin.Context) {
+		from, to, limit, ok := reportRange(c)
+		if !ok {
+			return
+		}
+
+		res, err := sql.GetClickThroughRates(from, to, limit)
+		if err != nil {
+			panic(err)
+		}
+
+		c.JSON(http.StatusOK, res)
+	})
+
+	r.GET("/api/quality", RequireAdmin(), func(c *gin.Context) {
+		from, size := 0, 100
+		if c.Query("from") != "" {
+			_from, err := strconv.Atoi(c.Query("from"))
+			if err != nil || _from < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a non-negative integer"})
+				return
 			}
-			document.Process(uuid.New().String(), res, c, files)
-		} else if c.Query("download") != "" && c.Query("type") == "tsv" {
-			document.CSV(uuid.New().String(), res, c)
+			from = _from
+		}
+		if c.Query("size") != "" {
+			_size, err := strconv.Atoi(c.Query("size"))
+			if err != nil || _size < 1 || _size > 1000 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "size must be between 1 and 1000"})
+				return
+			}
+			size = _size
+		}
+		if from+size > 10000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from + size must not exceed 10000"})
+			return
+		}
+
+		res, err := queries.CheckQuality(c.Query("check"), from, size)
+		if errors.Is(err, queries.ErrUnknownQualityCheck) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		c.JSON(http.StatusOK, res)
+	})
+
+	r.GET("/api/v1/contracts/search", func(c *gin.Context) {
+		args, err := queries.LegacyArgumentsFromValues(c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		res, err := queries.SearchInMaster(args)
+		if err != nil {
+			panic(err)
+		}
+
+		if args.Download != nil && *args.Download {
+			document.CSV(uuid.New().String(), res.SearchResult, c)
 		} else {
 			c.JSON(http.StatusOK, res)
 		}
 	})
 
+	r.GET("/api/search/profiles", func(c *gin.Context) {
+		profiles, defaultProfile := queries.RelevanceProfiles()
+
+		c.JSON(http.StatusOK, gin.H{"default": defaultProfile, "profiles": profiles})
+	})
+
+	r.GET("/api/suggest", func(c *gin.Context) {
+		size := 10
+		if c.Query("size") != "" {
+			_size, err := strconv.Atoi(c.Query("size"))
+			if err != nil || _size < 1 || _size > 50 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "size must be between 1 and 50"})
+				return
+			}
+			size = _size
+		}
+
+		res, err := queries.Suggest(c.Query("field"), c.Query("q"), size)
+		if errors.Is(err, queries.ErrUnknownSuggestField) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		c.JSON(http.StatusOK, res)
+	})
+
 	r.GET("/api/contracts/:id", func(c *gin.Context) {
 		id := c.Param("id")
 
@@ -211,6 +696,59 @@ func main() {
 		c.JSON(http.StatusOK, map[string]interface{}{"text": contractJson["pdf_text_string"]})
 	})
 
+	r.GET("/api/contracts/:id/search", func(c *gin.Context) {
+		if _, err := strconv.Atoi(c.Param("id")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a contract ID"})
+			return
+		}
+
+		q := c.Query("q")
+		if q == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+			return
+		}
+
+		pages, err := sql.GetContractPages(c.Param("id"))
+		if err != nil {
+			panic(err)
+		}
+		if len(pages) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "contract " + c.Param("id") + " has no pages"})
+			return
+		}
+
+		res, err := queries.SearchContractPages(pages, q)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	})
+
+	r.GET("/api/contracts/:id/similar", func(c *gin.Context) {
+		size := 10
+		if c.Query("size") != "" {
+			_size, err := strconv.Atoi(c.Query("size"))
+			if err != nil || _size < 1 || _size > 50 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "size must be between 1 and 50"})
+				return
+			}
+			size = _size
+		}
+
+		res, err := queries.SimilarContracts(c.Param("id"), size)
+		if errors.Is(err, queries.ErrContractNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		c.JSON(http.StatusOK, res)
+	})
+
 	r.GET("/api/page/:id", func(c *gin.Context) {
 		id := c.Param("id")
 		locale := c.Query("locale")
@@ -255,6 +793,73 @@ func main() {
 		c.JSON(http.StatusOK, res)
 	})
 
+	saved := r.Group("/api/saved-searches", RequireAdmin())
+
+	saved.GET("", func(c *gin.Context) {
+		res, err := sql.GetSavedSearches()
+		if err != nil {
+			panic(err)
+		}
+
+		c.JSON(http.StatusOK, res)
+	})
+
+	saved.POST("", func(c *gin.Context) {
+		var data struct {
+			Name  string  `json:"name"`
+			Query string  `json:"query"`
+			Email *string `json:"email"`
+		}
+
+		if err := c.BindJSON(&data); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		values, err := url.ParseQuery(data.Query)
+		if err == nil {
+			_, err = queries.SearchParamsFromValues(values)
+		}
+		if data.Name == "" || err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name and a valid /api/search query are required"})
+			return
+		}
+
+		res, err := sql.CreateSavedSearch(data.Name, data.Query, data.Email)
+		if err != nil {
+			panic(err)
+		}
+
+		c.JSON(http.StatusOK, res)
+	})
+
+	saved.DELETE("/:id", func(c *gin.Context) {
+		if err := sql.DeleteSavedSearch(c.Param("id")); err != nil {
+			panic(err)
+		}
+
+		c.JSON(http.StatusOK, nil)
+	})
+
+	saved.GET("/:id/matches", func(c *gin.Context) {
+		var since *time.Time
+		if c.Query("since") != "" {
+			t, err := time.Parse("2006-01-02", c.Query("since"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a YYYY-MM-DD date"})
+				return
+			}
+			since = &t
+		}
+
+		res, err := sql.GetSavedSearchMatches(c.Param("id"), since)
+		if err != nil {
+			panic(err)
+		}
+
+		c.JSON(http.StatusOK, res)
+	})
+
 	r.POST("/api/correction/resources", func(c *gin.Context) {
 		index := os.Getenv("ELASTICSEARCH_SECONDARY")
 		docType := os.Getenv("ELASTICSEARCH_DOC_MASTER")
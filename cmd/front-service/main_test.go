@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"iltodgeree/api/internal/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeElastic answers every search with no hits and records the search
+// bodies.
+func fakeElastic(t *testing.T) *[]string {
+	searches := &[]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.HasSuffix(r.URL.Path, "/_search") {
+			fmt.Fprint(w, `{"version": {"number": "5.6.16"}}`)
+			return
+		}
+		var body bytes.Buffer
+		body.ReadFrom(r.Body)
+		*searches = append(*searches, body.String())
+		fmt.Fprint(w, `{"took": 1, "hits": {"total": 0, "hits": []}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("ELASTICSEARCH_HOST", server.URL)
+	t.Setenv("ELASTICSEARCH_SECONDARY", "contracts")
+	t.Setenv("ELASTICSEARCH_DOC_MASTER", "master")
+	return searches
+}
+
+func TestSearchDebugRequiresAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		adminToken    string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "admin token", adminToken: "secret", authorization: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "wrong token", adminToken: "secret", authorization: "Bearer guess", wantStatus: http.StatusForbidden},
+		{name: "no token", adminToken: "secret", wantStatus: http.StatusForbidden},
+		{name: "ADMIN_TOKEN unset", authorization: "Bearer ", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searches := fakeElastic(t)
+			t.Setenv("ADMIN_TOKEN", tt.adminToken)
+
+			var logs bytes.Buffer
+			saved := slog.Default()
+			t.Cleanup(func() { slog.SetDefault(saved) })
+			slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+			r := gin.New()
+			r.GET("/api/search", searchHandler)
+			req := httptest.NewRequest(http.MethodGet, "/api/search?debug=true", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(*searches) != 0 || strings.Contains(logs.String(), "search request") {
+					t.Errorf("rejected debug search reached Elasticsearch: %v, logs %s", *searches, logs.String())
+				}
+				return
+			}
+
+			var res struct {
+				Debug *struct {
+					Request map[string]interface{} `json:"request"`
+				} `json:"debug"`
+				SearchID int64 `json:"search_id"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Debug == nil || res.Debug.Request["explain"] != true {
+				t.Errorf("debug search response = %s, want the debug request with explain", w.Body)
+			}
+			if res.SearchID != 0 {
+				t.Errorf("debug search was logged as search %d", res.SearchID)
+			}
+			if len(*searches) != 1 || !strings.Contains((*searches)[0], `"profile":true`) {
+				t.Errorf("searches = %v, want one profiled search", *searches)
+			}
+			if !strings.Contains(logs.String(), `"msg":"search request"`) {
+				t.Errorf("debug log = %s, want the search request entry", logs.String())
+			}
+		})
+	}
+}
+
+func TestSearchDebugInvalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/search", searchHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/search?debug=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("debug=maybe status = %d, want 400", w.Code)
+	}
+}
+
+func TestSearchLogging(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		query  string
+		logged bool
+	}{
+		{name: "search", query: "q=Gold&resource=41", logged: true},
+		{name: "download", query: "q=Gold&download=true&type=pdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeElastic(t)
+
+			var entries []sql.SearchLogEntry
+			saved := logSearch
+			t.Cleanup(func() { logSearch = saved })
+			logSearch = func(entry sql.SearchLogEntry) (int64, error) {
+				entries = append(entries, entry)
+				return 7, nil
+			}
+
+			r := gin.New()
+			r.GET("/api/search", searchHandler)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/search?"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			var res struct {
+				SearchID int64 `json:"search_id"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &res)
+			if !tt.logged {
+				if len(entries) != 0 || res.SearchID != 0 {
+					t.Errorf("logged %+v with search_id %d, want nothing", entries, res.SearchID)
+				}
+				return
+			}
+			if len(entries) != 1 || entries[0].Query != "gold" || fmt.Sprint(entries[0].Filters) != "map[resource:[41]]" || res.SearchID != 7 {
+				t.Errorf("logged %+v with search_id %d, want gold with resource 41 as 7", entries, res.SearchID)
+			}
+		})
+	}
+}
+
+func TestReportRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	tests := []struct {
+		query     string
+		wantFrom  time.Time
+		wantTo    time.Time
+		wantLimit int
+		wantOK    bool
+	}{
+		{query: "", wantFrom: today.AddDate(0, 0, -29), wantTo: today.AddDate(0, 0, 1), wantLimit: 50, wantOK: true},
+		{query: "from=2026-01-01&to=2026-01-31&limit=10", wantFrom: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), wantTo: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), wantLimit: 10, wantOK: true},
+		{query: "from=01/01/2026"},
+		{query: "from=2026-02-01&to=2026-01-01"},
+		{query: "limit=0"},
+		{query: "limit=1001"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/admin/search/top-queries?"+tt.query, nil)
+
+		from, to, limit, ok := reportRange(c)
+		if ok != tt.wantOK {
+			t.Errorf("reportRange(%s) ok = %v, want %v", tt.query, ok, tt.wantOK)
+			continue
+		}
+		if !ok {
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("reportRange(%s) status = %d, want 400", tt.query, w.Code)
+			}
+			continue
+		}
+		if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) || limit != tt.wantLimit {
+			t.Errorf("reportRange(%s) = %v, %v, %d, want %v, %v, %d", tt.query, from, to, limit, tt.wantFrom, tt.wantTo, tt.wantLimit)
+		}
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_TOKEN", "secret")
+
+	r := gin.New()
+	r.GET("/api/admin/search/top-queries", RequireAdmin(), func(c *gin.Context) { c.JSON(http.StatusOK, nil) })
+
+	for authorization, want := range map[string]int{"Bearer secret": http.StatusOK, "Bearer guess": http.StatusForbidden, "": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/search/top-queries", nil)
+		req.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("Authorization %q status = %d, want %d", authorization, w.Code, want)
+		}
+	}
+}
+
+func TestDistrictMapProvinceID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/map/districts", districtMapHandler)
+
+	for _, query := range []string{"", "province_id=", "province_id=Өмнөговь", "province_id=12&signed_from=2015-13-01"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/map/districts?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q status = %d, want 400: %s", query, w.Code, w.Body)
+		}
+	}
+}
+
+func TestRunQualitySetupErrors(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	tests := []struct {
+		name string
+		env  bool
+		args []string
+	}{
+		{name: "size too small", env: true, args: []string{"-size", "0"}},
+		{name: "size too large", env: true, args: []string{"-size", "10001"}},
+		{name: "unknown threshold", env: true, args: []string{"-max", "nope=1"}},
+		{name: "unknown flag", env: true, args: []string{"-limit", "1"}},
+		{name: "missing .env", args: []string{}},
+		{name: "unreachable Elasticsearch", env: true, args: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.env {
+				if err := os.WriteFile(filepath.Join(dir, ".env"), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.Chdir(dir); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("ELASTICSEARCH_HOST", "http://127.0.0.1:1")
+
+			if code := runQuality(tt.args); code != 2 {
+				t.Errorf("runQuality(%v) = %d, want 2", tt.args, code)
+			}
+		})
+	}
+}
@@ -2,7 +2,10 @@ module iltodgeree/api
 
 go 1.23.4
 
-require github.com/elastic/go-elasticsearch/v5 v5.6.1
+require (
+	github.com/elastic/go-elasticsearch/v5 v5.6.1
+	github.com/jackc/pgx/v5 v5.7.4
+)
 
 require (
 	github.com/adrg/strutil v0.3.1 // indirect
@@ -28,7 +31,6 @@ require (
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
 	github.com/jackc/pgx v3.6.2+incompatible // indirect
-	github.com/jackc/pgx/v5 v5.7.4 // indirect
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
 	github.com/joho/godotenv v1.5.1 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
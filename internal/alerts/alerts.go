@@ -0,0 +1,225 @@
+// Package alerts re-runs saved searches in the background and reports
+// contracts that were published since the previous run.
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iltodgeree/api/internal/queries"
+	"iltodgeree/api/internal/sql"
+	"log/slog"
+	"mime"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errSMTPNotConfigured is returned by notify when SMTP_HOST is not set.
+var errSMTPNotConfigured = errors.New("SMTP_HOST is not set")
+
+// The saved search storage, replaced in tests.
+var (
+	getSavedSearches                = sql.GetSavedSearches
+	addSavedSearchMatches           = sql.AddSavedSearchMatches
+	getUnnotifiedSavedSearchMatches = sql.GetUnnotifiedSavedSearchMatches
+	markSavedSearchMatchesNotified  = sql.MarkSavedSearchMatchesNotified
+)
+
+// matchPageSize is the number of hits fetched per page of a saved search.
+var matchPageSize = 500
+
+// createdAtLayouts lists the created_at formats found in the index.
+var createdAtLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// Start runs every saved search immediately and then once per interval.
+func Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			RunSavedSearches()
+			<-ticker.C
+		}
+	}()
+}
+
+// RunSavedSearches re-runs every saved search, records new matches and
+// emails the matches that were not sent yet to the search's recipient when
+// SMTP is configured. Matches whose alert failed are sent again on the next
+// run.
+func RunSavedSearches() {
+	searches, err := getSavedSearches()
+	if err != nil {
+		slog.Error("error reading saved searches", "error", err)
+		return
+	}
+
+	for _, search := range searches {
+		if err := runSavedSearch(search); err != nil {
+			slog.Error("error running saved search", "saved_search_id", search.ID, "error", err)
+			continue
+		}
+
+		// Without SMTP the matches stay unsent until it is configured.
+		if search.Email == nil || *search.Email == "" || os.Getenv("SMTP_HOST") == "" {
+			continue
+		}
+
+		matches, err := getUnnotifiedSavedSearchMatches(search.ID)
+		if err != nil {
+			slog.Error("error reading unsent saved search matches", "saved_search_id", search.ID, "error", err)
+			continue
+		}
+		if len(matches) == 0 {
+			continue
+		}
+
+		if err := notify(search, matches); err != nil {
+			slog.Error("error sending saved search alert", "saved_search_id", search.ID, "error", err)
+			continue
+		}
+
+		ids := make([]int, len(matches))
+		for i, match := range matches {
+			ids[i] = match.ID
+		}
+		if err := markSavedSearchMatchesNotified(ids); err != nil {
+			slog.Error("error marking saved search matches sent", "saved_search_id", search.ID, "error", err)
+		}
+	}
+}
+
+// runSavedSearch executes one saved search restricted to contracts created
+// after its last_created_at and records the hits as new matches. The hits
+// are read oldest first, a page at a time, and last_created_at moves
+// forward with each recorded page, so a failed run resumes after the last
+// page it recorded.
+func runSavedSearch(search sql.SavedSearch) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	values, err := url.ParseQuery(search.Query)
+	if err != nil {
+		return err
+	}
+	values.Del("cursor")
+	values.Del("facets")
+	values.Del("group_by")
+	values.Set("sort_by", "created_at")
+	values.Set("is_asc", "true")
+	values.Set("from", "0")
+	values.Set("size", strconv.Itoa(matchPageSize))
+
+	lastCreatedAt := search.LastCreatedAt
+	for {
+		params, err := queries.SearchParamsFromValues(values)
+		if err != nil {
+			return err
+		}
+		params.SetCreatedAfter(search.LastCreatedAt)
+
+		res, e := queries.SearchV2(params)
+		if *e != nil {
+			return *e
+		}
+
+		var matches []sql.SavedSearchMatch
+		for _, hit := range res.Hits.Hits {
+			var contract map[string]interface{}
+			if err := json.Unmarshal(*hit.Source, &contract); err != nil {
+				continue
+			}
+
+			createdAt, ok := parseCreatedAt(contract["created_at"])
+			if !ok {
+				continue
+			}
+			if createdAt.After(lastCreatedAt) {
+				lastCreatedAt = createdAt
+			}
+
+			name := ""
+			if metadata, ok := contract["metadata"].(map[string]interface{}); ok {
+				name, _ = metadata["contract_name"].(string)
+			}
+
+			matches = append(matches, sql.SavedSearchMatch{
+				ContractID:        hit.Id,
+				ContractName:      name,
+				ContractCreatedAt: createdAt,
+			})
+		}
+
+		if _, err := addSavedSearchMatches(search.ID, matches, lastCreatedAt); err != nil {
+			return err
+		}
+
+		if res.NextCursor == "" {
+			return nil
+		}
+		values.Set("cursor", res.NextCursor)
+	}
+}
+
+func parseCreatedAt(value interface{}) (time.Time, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	for _, layout := range createdAtLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// notify emails the new matches through the server configured by the
+// SMTP_* environment variables. Without SMTP_HOST nothing is sent and
+// errSMTPNotConfigured is returned.
+func notify(search sql.SavedSearch, matches []sql.SavedSearchMatch) error {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return errSMTPNotConfigured
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "25"
+	}
+
+	var auth smtp.Auth
+	if os.Getenv("SMTP_USERNAME") != "" {
+		auth = smtp.PlainAuth("", os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	from := os.Getenv("SMTP_FROM")
+	subject := fmt.Sprintf("%s: %d шинэ гэрээ", search.Name, len(matches))
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", from)
+	fmt.Fprintf(&body, "To: %s\r\n", *search.Email)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, match := range matches {
+		fmt.Fprintf(&body, "%s\r\n%s/api/contracts/download/%s/pdf\r\n\r\n",
+			match.ContractName, os.Getenv("PUBLIC_URL"), match.ContractID)
+	}
+
+	return smtp.SendMail(host+":"+port, auth, from, []string{*search.Email}, []byte(body.String()))
+}
@@ -0,0 +1,344 @@
+package alerts
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iltodgeree/api/internal/sql"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeContract is a contract served by the fake Elasticsearch.
+type fakeContract struct {
+	id        string
+	name      string
+	createdAt string
+}
+
+// newFakeElastic serves the contracts, oldest first, as the hits of every
+// search. It pages with size and search_after and ignores the query; the
+// search bodies are returned for inspection.
+func newFakeElastic(t *testing.T, contracts []fakeContract) *[]map[string]interface{} {
+	searches := &[]map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.HasSuffix(r.URL.Path, "/_search") {
+			fmt.Fprint(w, `{"version": {"number": "5.6.16"}}`)
+			return
+		}
+
+		var body map[string]interface{}
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("fake elastic: invalid search body %s: %v", data, err)
+		}
+		*searches = append(*searches, body)
+
+		// Sort values are the position of the contract and its _uid.
+		start := 0
+		if after, ok := body["search_after"].([]interface{}); ok {
+			start = int(after[0].(float64)) + 1
+		}
+		size := int(body["size"].(float64))
+
+		hits := []interface{}{}
+		for i := start; i < len(contracts) && i < start+size; i++ {
+			c := contracts[i]
+			hits = append(hits, map[string]interface{}{
+				"_index":  "contracts",
+				"_type":   "master",
+				"_id":     c.id,
+				"_source": map[string]interface{}{"created_at": c.createdAt, "metadata": map[string]interface{}{"contract_name": c.name}},
+				"sort":    []interface{}{i, "master#" + c.id},
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"took": 1,
+			"hits": map[string]interface{}{"total": len(contracts), "hits": hits},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("ELASTICSEARCH_HOST", server.URL)
+	t.Setenv("ELASTICSEARCH_SECONDARY", "contracts")
+	t.Setenv("ELASTICSEARCH_DOC_MASTER", "master")
+	return searches
+}
+
+// fakeSMTP is a mail catcher speaking just enough SMTP for smtp.SendMail.
+type fakeSMTP struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+// newFakeSMTP starts a mail catcher and points SMTP_HOST and SMTP_PORT at
+// it.
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	f := &fakeSMTP{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+	t.Setenv("SMTP_USERNAME", "")
+	return f
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 localhost\r\n")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch command := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			fmt.Fprint(conn, "250 localhost\r\n")
+		case command == "DATA":
+			fmt.Fprint(conn, "354 go ahead\r\n")
+			var message strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				message.WriteString(line)
+			}
+			f.mu.Lock()
+			f.messages = append(f.messages, message.String())
+			f.mu.Unlock()
+			fmt.Fprint(conn, "250 queued\r\n")
+		case command == "QUIT":
+			fmt.Fprint(conn, "221 bye\r\n")
+			return
+		default:
+			fmt.Fprint(conn, "250 ok\r\n")
+		}
+	}
+}
+
+func (f *fakeSMTP) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.messages...)
+}
+
+// addCall is a recorded call of addSavedSearchMatches.
+type addCall struct {
+	ids           []string
+	lastCreatedAt time.Time
+}
+
+func TestRunSavedSearch(t *testing.T) {
+	searches := newFakeElastic(t, []fakeContract{
+		{"11", "A", "2026-01-01T00:00:00Z"},
+		{"12", "B", "2026-01-02 10:00:00"},
+		{"13", "C", "2026-01-03"},
+		{"14", "D", "2026-01-04T08:00:00Z"},
+		{"15", "E", "2026-01-05T08:00:00Z"},
+	})
+
+	var calls []addCall
+	saved := addSavedSearchMatches
+	t.Cleanup(func() { addSavedSearchMatches = saved })
+	addSavedSearchMatches = func(searchID int, matches []sql.SavedSearchMatch, lastCreatedAt time.Time) ([]sql.SavedSearchMatch, error) {
+		call := addCall{lastCreatedAt: lastCreatedAt}
+		for _, m := range matches {
+			call.ids = append(call.ids, m.ContractID)
+		}
+		calls = append(calls, call)
+		return matches, nil
+	}
+
+	savedPageSize := matchPageSize
+	t.Cleanup(func() { matchPageSize = savedPageSize })
+	matchPageSize = 2
+
+	last := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+	search := sql.SavedSearch{ID: 7, Query: "resource=41&sort_by=contract_name&size=1&cursor=x", LastCreatedAt: last}
+	if err := runSavedSearch(search); err != nil {
+		t.Fatalf("runSavedSearch() error = %v", err)
+	}
+
+	want := []addCall{
+		{ids: []string{"11", "12"}, lastCreatedAt: time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{ids: []string{"13", "14"}, lastCreatedAt: time.Date(2026, 1, 4, 8, 0, 0, 0, time.UTC)},
+		{ids: []string{"15"}, lastCreatedAt: time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC)},
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("recorded pages = %+v, want %+v", calls, want)
+	}
+	for i := range want {
+		if strings.Join(calls[i].ids, ",") != strings.Join(want[i].ids, ",") || !calls[i].lastCreatedAt.Equal(want[i].lastCreatedAt) {
+			t.Errorf("page %d = %+v, want %+v", i, calls[i], want[i])
+		}
+	}
+
+	for i, body := range *searches {
+		sort, _ := json.Marshal(body["sort"])
+		if !strings.HasPrefix(string(sort), `[{"created_at":{"order":"asc"}}`) {
+			t.Errorf("search %d sort = %s, want created_at ascending", i, sort)
+		}
+		query, _ := json.Marshal(body["query"])
+		if !strings.Contains(string(query), `"created_at":{"from":"2025-12-31T00:00:00Z","include_lower":false`) {
+			t.Errorf("search %d query = %s, want contracts created after the last run", i, query)
+		}
+		if _, ok := body["search_after"]; ok != (i > 0) {
+			t.Errorf("search %d search_after = %v", i, body["search_after"])
+		}
+	}
+}
+
+func TestRunSavedSearchesNotify(t *testing.T) {
+	newFakeElastic(t, nil)
+	t.Setenv("SMTP_FROM", "alerts@iltodgeree.mn")
+	t.Setenv("PUBLIC_URL", "https://iltodgeree.mn")
+
+	email := "analyst@example.mn"
+	pending := []sql.SavedSearchMatch{
+		{ID: 3, ContractID: "11", ContractName: "Оюу Толгой гэрээ"},
+		{ID: 4, ContractID: "12", ContractName: "Таван Толгой гэрээ"},
+	}
+
+	stub := func(t *testing.T) *[][]int {
+		marked := &[][]int{}
+		saved := []interface{}{getSavedSearches, addSavedSearchMatches, getUnnotifiedSavedSearchMatches, markSavedSearchMatchesNotified}
+		t.Cleanup(func() {
+			getSavedSearches = saved[0].(func() ([]sql.SavedSearch, error))
+			addSavedSearchMatches = saved[1].(func(int, []sql.SavedSearchMatch, time.Time) ([]sql.SavedSearchMatch, error))
+			getUnnotifiedSavedSearchMatches = saved[2].(func(int) ([]sql.SavedSearchMatch, error))
+			markSavedSearchMatchesNotified = saved[3].(func([]int) error)
+		})
+
+		getSavedSearches = func() ([]sql.SavedSearch, error) {
+			return []sql.SavedSearch{
+				{ID: 1, Name: "Нүүрс", Query: "resource=27"},
+				{ID: 2, Name: "Алт", Query: "resource=41", Email: &email},
+			}, nil
+		}
+		addSavedSearchMatches = func(int, []sql.SavedSearchMatch, time.Time) ([]sql.SavedSearchMatch, error) {
+			return nil, nil
+		}
+		getUnnotifiedSavedSearchMatches = func(searchID int) ([]sql.SavedSearchMatch, error) {
+			if searchID != 2 {
+				t.Errorf("unsent matches read for search %d without an email", searchID)
+			}
+			return pending, nil
+		}
+		markSavedSearchMatchesNotified = func(ids []int) error {
+			*marked = append(*marked, ids)
+			return nil
+		}
+		return marked
+	}
+
+	t.Run("sent", func(t *testing.T) {
+		mail := newFakeSMTP(t)
+		marked := stub(t)
+
+		RunSavedSearches()
+
+		sent := mail.sent()
+		if len(sent) != 1 {
+			t.Fatalf("sent %d mails, want 1", len(sent))
+		}
+		for _, want := range []string{"To: analyst@example.mn", "Subject: =?utf-8?q?", "Оюу Толгой гэрээ", "https://iltodgeree.mn/api/contracts/download/12/pdf"} {
+			if !strings.Contains(sent[0], want) {
+				t.Errorf("mail = %q, want it to contain %q", sent[0], want)
+			}
+		}
+		if fmt.Sprint(*marked) != "[[3 4]]" {
+			t.Errorf("marked = %v, want [[3 4]]", *marked)
+		}
+	})
+
+	t.Run("mail fails", func(t *testing.T) {
+		marked := stub(t)
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		host, port, _ := net.SplitHostPort(listener.Addr().String())
+		listener.Close()
+		t.Setenv("SMTP_HOST", host)
+		t.Setenv("SMTP_PORT", port)
+
+		RunSavedSearches()
+
+		if len(*marked) != 0 {
+			t.Errorf("marked = %v after a failed mail, want nothing", *marked)
+		}
+	})
+
+	t.Run("SMTP not configured", func(t *testing.T) {
+		marked := stub(t)
+		t.Setenv("SMTP_HOST", "")
+		getUnnotifiedSavedSearchMatches = func(int) ([]sql.SavedSearchMatch, error) {
+			t.Errorf("unsent matches read without SMTP")
+			return pending, nil
+		}
+
+		RunSavedSearches()
+
+		if len(*marked) != 0 {
+			t.Errorf("marked = %v without SMTP, want nothing", *marked)
+		}
+	})
+}
+
+func TestNotifyWithoutSMTP(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	email := "analyst@example.mn"
+	err := notify(sql.SavedSearch{Name: "Алт", Email: &email}, []sql.SavedSearchMatch{{ContractID: "11"}})
+	if !errors.Is(err, errSMTPNotConfigured) {
+		t.Errorf("notify() error = %v, want errSMTPNotConfigured", err)
+	}
+}
+
+func TestParseCreatedAt(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  time.Time
+		ok    bool
+	}{
+		{"2026-01-02T03:04:05Z", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), true},
+		{"2026-01-02 03:04:05", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), true},
+		{"2026-01-02", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), true},
+		{"02/01/2026", time.Time{}, false},
+		{nil, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseCreatedAt(tt.value)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("parseCreatedAt(%v) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -124,6 +124,124 @@ var Resources = Map{
 	"115": "Гянболд",
 }
 
+// ResourcesEnglish maps numeric resource IDs to their English names.
+// It mirrors Resources and is used for bilingual labels and search synonyms.
+var ResourcesEnglish = Map{
+	"3":   "Agalmatolite",
+	"4":   "Loess",
+	"5":   "Clayey Soil",
+	"6":   "Concrete Mix",
+	"7":   "Aluminium",
+	"8":   "Amethyst",
+	"9":   "Aquamarine",
+	"10":  "Cement",
+	"11":  "Asphalt/Bitumen",
+	"12":  "Barium",
+	"13":  "Mixed Metals",
+	"14":  "Basalt",
+	"15":  "Bauxite",
+	"16":  "Beryl",
+	"17":  "Biofuel",
+	"18":  "Bismuth",
+	"19":  "Magnesite",
+	"20":  "Bituminous Coal",
+	"21":  "Brick",
+	"22":  "Boron",
+	"23":  "Brown Coal",
+	"24":  "Construction Materials",
+	"25":  "Cadmium",
+	"26":  "Clay",
+	"27":  "Coal",
+	"28":  "Coking Coal",
+	"29":  "Washed Coking Coal",
+	"30":  "Copper",
+	"31":  "Crushed Stone",
+	"32":  "Crude Oil",
+	"33":  "Crystal",
+	"34":  "Lime and Gypsum (Dolomite)",
+	"35":  "Fluorspar",
+	"36":  "Ferrochrome",
+	"37":  "Fluorspar",
+	"38":  "Hard Coal",
+	"39":  "Garnet",
+	"40":  "Natural Gas",
+	"41":  "Gold",
+	"42":  "Granite",
+	"43":  "Graphite",
+	"44":  "Gravel",
+	"45":  "Sand and Gravel",
+	"46":  "Green Granite",
+	"47":  "Gypsum",
+	"48":  "Rock Salt",
+	"49":  "Iron",
+	"50":  "Iron Ore",
+	"51":  "Iron Sand",
+	"52":  "Jade",
+	"53":  "Rare Earth Metals",
+	"54":  "Lead",
+	"55":  "Lime",
+	"56":  "Lime Sand",
+	"57":  "Limestone",
+	"58":  "Lithium",
+	"59":  "Magnetite",
+	"60":  "Manganese",
+	"61":  "Manganese Ore",
+	"62":  "Marble",
+	"63":  "Metallurgical/Coking Coal",
+	"64":  "Monument Stone",
+	"65":  "Mica",
+	"66":  "Molybdenum",
+	"67":  "Clay",
+	"68":  "Natural Gas (Gas and Liquids)",
+	"69":  "Nickel",
+	"70":  "Nickel Pig Iron",
+	"71":  "Non-ferrous Metals",
+	"72":  "Oil",
+	"73":  "Oil Shale",
+	"74":  "Shale",
+	"75":  "Ore",
+	"76":  "Tin",
+	"77":  "Peat",
+	"78":  "Perlite",
+	"79":  "Phosphate",
+	"80":  "Phosphorite",
+	"81":  "Polymetallic",
+	"82":  "Building Stone",
+	"83":  "Rare Earth Elements",
+	"84":  "Salt",
+	"85":  "Natural Sand",
+	"86":  "Sandstone",
+	"87":  "Beryl",
+	"88":  "Semi-coking Coal",
+	"89":  "Quartz",
+	"90":  "Silver",
+	"91":  "Fluorite",
+	"92":  "Fluorite Concentrate",
+	"93":  "Steel",
+	"94":  "River Stone",
+	"95":  "Sugar",
+	"96":  "Sulphur",
+	"97":  "Thermal Coal",
+	"98":  "Tin",
+	"99":  "Titanium",
+	"100": "Tungsten",
+	"101": "Raw Coal",
+	"102": "Uranium and Thorium Ores and Concentrates",
+	"103": "Volcanic Scoria",
+	"104": "Yttrium",
+	"105": "Zeolite",
+	"106": "Zinc",
+	"107": "Zircon",
+	"108": "Unknown",
+	"109": "Uranium",
+	"110": "Iron Ore Concentrate",
+	"111": "Common Minerals",
+	"112": "Bitumen",
+	"113": "Bismuth",
+	"114": "Other",
+	"115": "Tungsten",
+}
+
 // ContractTypesReverse maps Mongolian contract type names to their English equivalents.
 // Used for translating contract types when querying or filtering documents.
 var ContractTypesReverse = Map{
@@ -21,7 +21,7 @@ type Contract struct {
 	Filehas         string
 	UserID          int64
 	CreatedDateTime string
-	ParentID        int64
+	ParentID        int64 `json:"parent_id"` // Indexed under its column name
 	ProvinceID      int64
 	DistrictID      int64
 	ContractPages   []ContractPage
@@ -0,0 +1,82 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+var testTable = [][]string{
+	{"resource / year", "2015", "2016", "Нийт"},
+	{`Алт, "шороон"`, "2", "3", "5"},
+	{"Нүүрс & <хүрэн>", "2", "0", "2"},
+	{"Нийт", "4", "3", "7"},
+}
+
+func TestTableCSV(t *testing.T) {
+	data, err := TableCSV(testTable)
+	if err != nil {
+		t.Fatalf("TableCSV() error = %v", err)
+	}
+
+	want := "resource / year,2015,2016,Нийт\n" +
+		`"Алт, ""шороон""",2,3,5` + "\n" +
+		"Нүүрс & <хүрэн>,2,0,2\n" +
+		"Нийт,4,3,7\n"
+	if string(data) != want {
+		t.Errorf("TableCSV() = %q, want %q", data, want)
+	}
+}
+
+func TestTableXLSX(t *testing.T) {
+	data, err := TableXLSX(testTable)
+	if err != nil {
+		t.Fatalf("TableXLSX() error = %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("TableXLSX() is not a zip archive: %v", err)
+	}
+
+	parts := map[string]string{}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(r)
+		r.Close()
+		parts[file.Name] = string(content)
+	}
+	for _, part := range xlsxParts {
+		if _, ok := parts[part.Name]; !ok {
+			t.Errorf("TableXLSX() has no %s", part.Name)
+		}
+	}
+
+	sheet, ok := parts["xl/worksheets/sheet1.xml"]
+	if !ok {
+		t.Fatal("TableXLSX() has no worksheet")
+	}
+	for _, cell := range []string{
+		`<row r="1"><c r="A1" t="inlineStr"><is><t>resource / year</t></is></c><c r="B1"><v>2015</v></c>`,
+		`<c r="A2" t="inlineStr"><is><t>Алт, &#34;шороон&#34;</t></is></c>`,
+		`<c r="A3" t="inlineStr"><is><t>Нүүрс &amp; &lt;хүрэн&gt;</t></is></c>`,
+		`<c r="D4"><v>7</v></c></row>`,
+	} {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("sheet = %s, want it to contain %s", sheet, cell)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for index, want := range tests {
+		if got := columnName(index); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", index, got, want)
+		}
+	}
+}
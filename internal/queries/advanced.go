@@ -0,0 +1,233 @@
+package queries
+
+import (
+	"errors"
+	"fmt"
+	"iltodgeree/api/internal/correction"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// ErrQuerySyntax is returned for a q that uses the field syntax wrongly.
+var ErrQuerySyntax = errors.New("q: invalid query syntax")
+
+// fieldPrefixes maps the prefixes of the advanced q syntax, such as
+// `company:"Oyu Tolgoi"`, to the field they search.
+var fieldPrefixes = map[string]string{
+	"company":    "metadata.company_name",
+	"government": "metadata.government_entity.entity",
+	"resource":   "metadata.resource_raw",
+	"type":       "metadata.contract_type",
+	"doctype":    "metadata.document_type",
+	"project":    "metadata.project_title",
+	"title":      "metadata.contract_name",
+	"year":       "metadata.signature_year",
+	"text":       "pdf_text_string",
+}
+
+// contractTypeAbbreviations lets type: take the usual short forms.
+var contractTypeAbbreviations = map[string]string{
+	"mou": "Memorandum of Understanding",
+}
+
+// fieldClause is a `prefix:value` part of q.
+type fieldClause struct {
+	prefix  string
+	value   string
+	phrase  bool // The value was quoted
+	negated bool // The clause was written as -prefix:value
+}
+
+// syntaxError wraps ErrQuerySyntax with the problem and the known field
+// prefixes.
+func syntaxError(format string, args ...interface{}) error {
+	prefixes := make([]string, 0, len(fieldPrefixes))
+	for prefix := range fieldPrefixes {
+		prefixes = append(prefixes, prefix+":")
+	}
+	sort.Strings(prefixes)
+	return fmt.Errorf("%w: %s; known prefixes are %s", ErrQuerySyntax, fmt.Sprintf(format, args...), strings.Join(prefixes, " "))
+}
+
+// parseFieldQuery splits q into its field clauses and the remaining bare
+// terms, which keep the simple_query_string syntax. Prefixes inside quoted
+// phrases are not parsed.
+func parseFieldQuery(q string) ([]fieldClause, string, error) {
+	runes := []rune(q)
+	var clauses []fieldClause
+	var rest strings.Builder
+	inQuote := false
+
+	for i := 0; i < len(runes); {
+		atWordStart := i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '('
+		if !inQuote && atWordStart {
+			clause, n, err := scanFieldClause(runes[i:])
+			if err != nil {
+				return nil, "", err
+			}
+			if n > 0 {
+				clauses = append(clauses, clause)
+				i += n
+				continue
+			}
+		}
+
+		if runes[i] == '"' {
+			inQuote = !inQuote
+		}
+		rest.WriteRune(runes[i])
+		i++
+	}
+
+	return clauses, strings.Join(strings.Fields(rest.String()), " "), nil
+}
+
+// scanFieldClause reads a field clause at the start of runes. It returns
+// the number of runes consumed, or 0 when runes does not start with one.
+func scanFieldClause(runes []rune) (fieldClause, int, error) {
+	clause := fieldClause{}
+	i := 0
+	if i < len(runes) && runes[i] == '-' {
+		clause.negated = true
+		i++
+	}
+
+	start := i
+	for i < len(runes) && (unicode.IsLetter(runes[i]) || runes[i] == '_') {
+		i++
+	}
+	if i == start || i == len(runes) || runes[i] != ':' {
+		return clause, 0, nil
+	}
+
+	clause.prefix = strings.ToLower(string(runes[start:i]))
+	if _, ok := fieldPrefixes[clause.prefix]; !ok {
+		if isPlainColon(runes[i+1:]) {
+			return clause, 0, nil
+		}
+		return clause, 0, syntaxError("unknown prefix %s:", clause.prefix)
+	}
+	i++
+
+	if i < len(runes) && runes[i] == '"' {
+		end := i + 1
+		for end < len(runes) && runes[end] != '"' {
+			end++
+		}
+		if end == len(runes) {
+			return clause, 0, syntaxError("missing closing quote after %s:", clause.prefix)
+		}
+		clause.value = strings.TrimSpace(string(runes[i+1 : end]))
+		clause.phrase = true
+		i = end + 1
+	} else {
+		start = i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			i++
+		}
+		clause.value = string(runes[start:i])
+	}
+
+	if clause.value == "" {
+		return clause, 0, syntaxError("%s: needs a value", clause.prefix)
+	}
+
+	return clause, i, nil
+}
+
+// isPlainColon reports whether the text after an unknown prefix's colon
+// makes it plain text rather than a mistyped field: a URL such as
+// https://example.mn, a code such as ISO:9001, or a colon ending a word.
+func isPlainColon(after []rune) bool {
+	if len(after) == 0 || unicode.IsSpace(after[0]) || unicode.IsDigit(after[0]) {
+		return true
+	}
+	return strings.HasPrefix(string(after), "//")
+}
+
+// fieldQuery builds the query of a field clause. Known resources, contract
+// types and document types are matched exactly in either language,
+// everything else is matched as text.
+func (s *SearchParams) fieldQuery(c fieldClause) elastic.Query {
+	switch c.prefix {
+	case "company":
+		return s.nameFilter("metadata.company_name.keyword", "metadata.company_name", c.value)
+	case "government":
+		return s.nameFilter("metadata.government_entity.entity.keyword", "metadata.government_entity.entity", c.value)
+	case "resource":
+		if ids := resourceIDs(c.value); len(ids) > 0 {
+			return elastic.NewTermsQuery("metadata.resource", interfaces(ids)...)
+		}
+	case "type":
+		if abbreviation, ok := contractTypeAbbreviations[strings.ToLower(c.value)]; ok {
+			return elastic.NewTermQuery("metadata.contract_type.keyword", abbreviation)
+		}
+		if names := englishNames(c.value, correction.ContractTypes, correction.ContractTypesReverse); len(names) > 0 {
+			return elastic.NewTermsQuery("metadata.contract_type.keyword", interfaces(names)...)
+		}
+	case "doctype":
+		if names := englishNames(c.value, correction.DocumentTypes, correction.DocumentTypesReverse); len(names) > 0 {
+			return elastic.NewTermsQuery("metadata.document_type.keyword", interfaces(names)...)
+		}
+	case "year":
+		return elastic.NewTermQuery("metadata.signature_year", c.value)
+	}
+
+	field := fieldPrefixes[c.prefix]
+	if c.phrase {
+		return elastic.NewMatchPhraseQuery(field, c.value)
+	}
+	return elastic.NewMatchQuery(field, c.value).Operator("and")
+}
+
+// resourceIDs finds the IDs of a resource given by ID or by its Mongolian
+// or English name. Several resources share a name, e.g. Clay is 26 and 67.
+func resourceIDs(value string) []string {
+	if _, ok := correction.Resources[value]; ok {
+		return []string{value}
+	}
+
+	normalized := normalizePhrase(value)
+	seen := map[string]bool{}
+	ids := []string{}
+	for _, names := range []correction.Map{correction.Resources, correction.ResourcesEnglish} {
+		for id, name := range names {
+			if normalizePhrase(name) == normalized && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// englishNames finds the stored English names of a type given in either
+// language.
+func englishNames(value string, english correction.Map, mongolian correction.Map) []string {
+	normalized := normalizePhrase(value)
+	seen := map[string]bool{}
+	names := []string{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for name := range english {
+		if normalizePhrase(name) == normalized {
+			add(name)
+		}
+	}
+	for name, en := range mongolian {
+		if normalizePhrase(name) == normalized {
+			add(en)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,90 @@
+package queries
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseFieldQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		q        string
+		want     []fieldClause
+		wantRest string
+		wantErr  bool
+	}{
+		{
+			name: "mixed prefixes and bare terms",
+			q:    `company:"Oyu Tolgoi" resource:copper text:"royalty rate" -type:MoU tax`,
+			want: []fieldClause{
+				{prefix: "company", value: "Oyu Tolgoi", phrase: true},
+				{prefix: "resource", value: "copper"},
+				{prefix: "text", value: "royalty rate", phrase: true},
+				{prefix: "type", value: "MoU", negated: true},
+			},
+			wantRest: "tax",
+		},
+		{name: "bare terms only", q: `"gold mine" -coal`, wantRest: `"gold mine" -coal`},
+		{name: "prefix inside a phrase", q: `"see company:Oyu"`, wantRest: `"see company:Oyu"`},
+		{name: "colon without prefix", q: "10:30", wantRest: "10:30"},
+		{name: "codes and URLs are text", q: "ISO:9001 https://example.mn Тайлбар: алт", wantRest: "ISO:9001 https://example.mn Тайлбар: алт"},
+		{name: "unknown prefix", q: `compnay:"Oyu Tolgoi"`, wantErr: true},
+		{name: "unknown negated prefix", q: "-owner:Oyu", wantErr: true},
+		{name: "missing value", q: "company: Oyu", wantErr: true},
+		{name: "unterminated quote", q: `company:"Oyu Tolgoi`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clauses, rest, err := parseFieldQuery(tt.q)
+			if tt.wantErr {
+				if !errors.Is(err, ErrQuerySyntax) || !strings.Contains(err.Error(), "company: doctype:") {
+					t.Errorf("parseFieldQuery(%q) error = %v, want ErrQuerySyntax listing the prefixes", tt.q, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFieldQuery(%q) error = %v", tt.q, err)
+			}
+			if rest != tt.wantRest {
+				t.Errorf("parseFieldQuery(%q) rest = %q, want %q", tt.q, rest, tt.wantRest)
+			}
+			if len(clauses) != len(tt.want) {
+				t.Fatalf("parseFieldQuery(%q) = %v, want %v", tt.q, clauses, tt.want)
+			}
+			for i := range clauses {
+				if clauses[i] != tt.want[i] {
+					t.Errorf("clause %d = %v, want %v", i, clauses[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFieldQuery(t *testing.T) {
+	params := NewSearchParams("", "", "", "", "", "", "")
+	tests := []struct {
+		clause fieldClause
+		want   string
+	}{
+		{fieldClause{prefix: "resource", value: "copper"}, `{"terms":{"metadata.resource":["30"]}}`},
+		{fieldClause{prefix: "resource", value: "Алт"}, `{"terms":{"metadata.resource":["41"]}}`},
+		{fieldClause{prefix: "resource", value: "41"}, `{"terms":{"metadata.resource":["41"]}}`},
+		{fieldClause{prefix: "resource", value: "clay"}, `{"terms":{"metadata.resource":["26","67"]}}`},
+		{fieldClause{prefix: "resource", value: "Tin"}, `{"terms":{"metadata.resource":["76","98"]}}`},
+		{fieldClause{prefix: "resource", value: "Хайлуур Жонш"}, `{"terms":{"metadata.resource":["35","37"]}}`},
+		{fieldClause{prefix: "resource", value: "tungsten"}, `{"terms":{"metadata.resource":["100","115"]}}`},
+		{fieldClause{prefix: "type", value: "MoU"}, `{"term":{"metadata.contract_type.keyword":"Memorandum of Understanding"}}`},
+		{fieldClause{prefix: "type", value: "Концессийн гэрээ"}, `{"terms":{"metadata.contract_type.keyword":["Concession Agreement"]}}`},
+		{fieldClause{prefix: "text", value: "royalty rate", phrase: true}, `{"match_phrase":{"pdf_text_string":{"query":"royalty rate"}}}`},
+		{fieldClause{prefix: "project", value: "tolgoi"}, `{"match":{"metadata.project_title":{"operator":"and","query":"tolgoi"}}}`},
+	}
+	for _, tt := range tests {
+		src, _ := params.fieldQuery(tt.clause).Source()
+		body, _ := json.Marshal(src)
+		if string(body) != tt.want {
+			t.Errorf("fieldQuery(%v) = %s, want %s", tt.clause, body, tt.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	appcontext "iltodgeree/api/internal/app_context"
 	"log"
+	"os"
 
 	"gopkg.in/olivere/elastic.v5"
 )
@@ -68,14 +69,18 @@ func YearFilterAggregations(provinceID int) (*[]map[string]interface{}, error) {
 	return &data, nil
 }
 
-// Aggregations computes comprehensive statistics across all contracts.
+// Aggregations computes comprehensive statistics across the contracts
+// matching the search parameters, selected the same way as /api/search.
 // Includes aggregations by year, resource, type, country, province, company, etc.
 // Also computes resource distribution by year for trend analysis.
 //
+// Parameters:
+//   - params: Search parameters from SearchParamsFromValues; empty parameters cover all contracts
+//
 // Returns:
 //   - *map[string]interface{}: Map containing 'aggs' (aggregations) and 'count' (total)
 //   - error: Error if query fails
-func Aggregations() (*map[string]interface{}, error) {
+func Aggregations(params *SearchParams) (*map[string]interface{}, error) {
 	client, err := appcontext.ElasticInstance.GetV5()
 	if err != nil {
 		panic(err)
@@ -95,22 +100,14 @@ func Aggregations() (*map[string]interface{}, error) {
 	districts := elastic.NewTermsAggregation().Field("metadata.provinces.district.keyword").Size(aggSize)
 	annotationCategories := elastic.NewTermsAggregation().Field("annotations_category.keyword").Size(aggSize)
 
-	index := "iltodgeree_v2.2"
-	docType := "master"
-
-	countService := client.Count().
-		Index(index). // Your index
-		Type(docType) // Your document type (for ES5, types still exist)
-
-	ctx := context.Background()
-	count, err := countService.Do(ctx)
-	if err != nil {
-		return nil, err
-	}
+	index := os.Getenv("ELASTICSEARCH_SECONDARY")
+	docType := os.Getenv("ELASTICSEARCH_DOC_MASTER")
+	query := params.filterQuery(flattenFilters(params.facetFilters(), ""))
 
 	result, err := client.Search().
 		Index(index).
 		Type(docType).
+		Query(query).
 		Size(0).
 		Aggregation("year_summary", year).
 		Aggregation("resource_summary", resource).
@@ -137,7 +134,7 @@ func Aggregations() (*map[string]interface{}, error) {
 
 	return &map[string]interface{}{
 		"aggs":  result.Aggregations,
-		"count": count,
+		"count": result.Hits.TotalHits,
 	}, nil
 }
 
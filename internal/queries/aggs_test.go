@@ -0,0 +1,35 @@
+package queries
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+)
+
+func TestAggregationsUseSearchFilters(t *testing.T) {
+	f := newFakeElastic(t, "testdata/company_contracts.json")
+
+	values := url.Values{"company": {"Erdenes*"}, "exclude_government": {"Сангийн*"}}
+	params, err := SearchParamsFromValues(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Aggregations(params)
+	if err != nil {
+		t.Fatalf("Aggregations() error = %v", err)
+	}
+	if count := (*res)["count"]; count != int64(2) {
+		t.Errorf("Aggregations() count = %v, want 2", count)
+	}
+
+	if _, e := SearchV2(params); *e != nil {
+		t.Fatalf("SearchV2() error = %v", *e)
+	}
+
+	summary, _ := json.Marshal(f.searches[0]["query"])
+	search, _ := json.Marshal(f.searches[1]["query"])
+	if string(summary) != string(search) {
+		t.Errorf("Aggregations() query = %s, want the search query %s", summary, search)
+	}
+}
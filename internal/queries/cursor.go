@@ -0,0 +1,78 @@
+package queries
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// tiebreakerField makes the sort order total so that search_after can resume
+// exactly where the previous page stopped. ES 5 cannot sort on _id, so the
+// equivalent _uid (type#id) is used instead.
+var tiebreakerField = "_uid"
+
+// cursor is the decoded form of the opaque cursor parameter.
+type cursor struct {
+	SortBy string        `json:"s"`
+	Asc    bool          `json:"a"`
+	Values []interface{} `json:"v"`
+}
+
+// encodeCursor serializes the sort values of the last hit of a page.
+func encodeCursor(sortBy string, asc bool, values []interface{}) string {
+	normalized := make([]interface{}, len(values))
+	for i, v := range values {
+		normalized[i] = sortValue(v)
+	}
+
+	data, err := json.Marshal(cursor{SortBy: sortBy, Asc: asc, Values: normalized})
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor produced by encodeCursor.
+func decodeCursor(value string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %v", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var c cursor
+	if err := decoder.Decode(&c); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %v", err)
+	}
+
+	if len(c.Values) == 0 {
+		return nil, fmt.Errorf("invalid cursor: no sort values")
+	}
+
+	return &c, nil
+}
+
+// sortValue restores the integer form of a numeric sort value. The client
+// decodes numbers as float64, which cannot hold the long sentinels ES uses
+// for documents missing the sort field.
+func sortValue(v interface{}) interface{} {
+	f, ok := v.(float64)
+	if !ok || f != math.Trunc(f) {
+		return v
+	}
+
+	if f >= math.MaxInt64 {
+		return json.Number(strconv.FormatInt(math.MaxInt64, 10))
+	}
+	if f <= math.MinInt64 {
+		return json.Number(strconv.FormatInt(math.MinInt64, 10))
+	}
+
+	return json.Number(strconv.FormatInt(int64(f), 10))
+}
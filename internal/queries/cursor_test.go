@@ -0,0 +1,49 @@
+package queries
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []interface{}
+		want   string
+	}{
+		{name: "date and uid", values: []interface{}{float64(1331596800000), "master#123"}, want: `[1331596800000,"master#123"]`},
+		{name: "missing date", values: []interface{}{float64(math.MaxInt64), "master#9"}, want: `[9223372036854775807,"master#9"]`},
+		{name: "keyword", values: []interface{}{"Алт", "master#1"}, want: `["Алт","master#1"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := decodeCursor(encodeCursor("metadata.signature_date", true, tt.values))
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			got, _ := json.Marshal(c.Values)
+			if string(got) != tt.want {
+				t.Errorf("cursor values = %s, want %s", got, tt.want)
+			}
+			if c.SortBy != "metadata.signature_date" || !c.Asc {
+				t.Errorf("cursor sort = %s %v, want metadata.signature_date true", c.SortBy, c.Asc)
+			}
+		})
+	}
+}
+
+func TestSetCursorRejectsOtherSort(t *testing.T) {
+	params := NewSearchParams("", "", "", "", "", "", "")
+	params.SetSortBy("contract_name")
+	params.SetOrder("true")
+
+	if err := params.SetCursor("not a cursor"); err == nil {
+		t.Errorf("SetCursor() accepted a malformed cursor")
+	}
+
+	value := encodeCursor("metadata.signature_date", true, []interface{}{float64(1), "master#1"})
+	if err := params.SetCursor(value); err == nil {
+		t.Errorf("SetCursor() accepted a cursor issued for another sort")
+	}
+}
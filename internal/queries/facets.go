@@ -0,0 +1,168 @@
+package queries
+
+import (
+	"fmt"
+	"iltodgeree/api/internal/correction"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// Label holds the Mongolian and English display names of a facet value.
+type Label struct {
+	Mn string `json:"mn"`
+	En string `json:"en"`
+}
+
+// FacetBucket is a single selectable value of a facet with its hit count.
+// Key is the value to send back as the filter parameter. Label is left out
+// when the value has no display name other than its key.
+type FacetBucket struct {
+	Key      string `json:"key"`
+	DocCount int64  `json:"doc_count"`
+	Label    *Label `json:"label,omitempty"`
+}
+
+// facetAggregations builds one filter aggregation per dimension. Each
+// aggregation applies every active filter except its own, so the buckets
+// show the alternatives available for that dimension.
+func facetAggregations(grouped map[string][]elastic.Query) map[string]elastic.Aggregation {
+	aggSize := 10000
+	aggs := map[string]elastic.Aggregation{}
+
+	for _, d := range dimensions {
+		filter := elastic.NewBoolQuery().Filter(flattenFilters(grouped, d.name)...)
+		terms := elastic.NewTermsAggregation().Field(d.field).Size(aggSize)
+		aggs["facet_"+d.name] = elastic.NewFilterAggregation().Filter(filter).SubAggregation("values", terms)
+	}
+
+	return aggs
+}
+
+// parseFacets converts the facet aggregations of a search result into
+// labelled buckets keyed by dimension name.
+func parseFacets(aggs elastic.Aggregations) map[string][]FacetBucket {
+	facets := map[string][]FacetBucket{}
+
+	for _, d := range dimensions {
+		filtered, found := aggs.Filter("facet_" + d.name)
+		if !found {
+			continue
+		}
+		terms, found := filtered.Aggregations.Terms("values")
+		if !found {
+			continue
+		}
+
+		buckets := []FacetBucket{}
+		for _, bucket := range terms.Buckets {
+			key := fmt.Sprint(bucket.Key)
+			if bucket.KeyAsString != nil {
+				key = *bucket.KeyAsString
+			}
+			value, label := namedLabel(d.name, key)
+			buckets = append(buckets, FacetBucket{
+				Key:      value,
+				DocCount: bucket.DocCount,
+				Label:    label,
+			})
+		}
+		facets[d.name] = buckets
+	}
+
+	return facets
+}
+
+// facetLabel translates a stored value into the filter value accepted by
+// SearchParams and its bilingual label, which is the key itself when the
+// value has no name.
+func facetLabel(name string, key string) (string, Label) {
+	value, label := namedLabel(name, key)
+	if label == nil {
+		return value, Label{Mn: key, En: key}
+	}
+	return value, *label
+}
+
+// namedLabel is facetLabel without the fallback: the label is nil when the
+// value has no name other than its key.
+func namedLabel(name string, key string) (string, *Label) {
+	switch name {
+	case "resource":
+		if mn, ok := correction.Resources[key]; ok {
+			en, ok := correction.ResourcesEnglish[key]
+			if !ok {
+				en = mn
+			}
+			return key, &Label{Mn: mn, En: en}
+		}
+	case "contract_type":
+		if mn, ok := correction.ContractTypes[key]; ok {
+			return mn, &Label{Mn: mn, En: key}
+		}
+	case "document_type":
+		if mn, ok := correction.DocumentTypes[key]; ok {
+			return mn, &Label{Mn: mn, En: key}
+		}
+	case "province", "district":
+		if unit, ok := unitName(key); ok {
+			return key, &Label{Mn: unit, En: unit}
+		}
+	case "company", "government":
+		// A comma would split the name when it is passed back as a filter
+		// (see splitNames), so it is escaped and the name kept as label.
+		if strings.Contains(key, ",") {
+			return strings.ReplaceAll(key, ",", `\,`), &Label{Mn: key, En: key}
+		}
+	}
+
+	return key, nil
+}
+
+var (
+	unitNamesMu sync.Mutex
+	// unitNames caches the province and district names by ID once read.
+	unitNames map[int]string
+	// loadUnitNames reads the province and district names; nil until
+	// SetUnitNameLoader is called.
+	loadUnitNames func() (map[int]string, error)
+)
+
+// SetUnitNameLoader sets the function reading the province and district
+// names used as labels, e.g. sql.GetProvincesAllUnits. Until it is called,
+// provinces and districts are left without a label.
+func SetUnitNameLoader(load func() (map[int]string, error)) {
+	unitNamesMu.Lock()
+	defer unitNamesMu.Unlock()
+	loadUnitNames = load
+	unitNames = nil
+}
+
+// unitName returns the name of a province or district ID. The names are
+// read on first use; a failed read is retried on the next call.
+func unitName(key string) (string, bool) {
+	id, err := strconv.Atoi(key)
+	if err != nil {
+		return "", false
+	}
+
+	unitNamesMu.Lock()
+	defer unitNamesMu.Unlock()
+	if unitNames == nil {
+		if loadUnitNames == nil {
+			return "", false
+		}
+		names, err := loadUnitNames()
+		if err != nil {
+			slog.Warn("could not read unit names", "error", err)
+			return "", false
+		}
+		unitNames = names
+	}
+
+	name, ok := unitNames[id]
+	return name, ok
+}
@@ -0,0 +1,107 @@
+package queries
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFacetAggregationsExcludeOwnFilter(t *testing.T) {
+	params := NewSearchParams("", "2015", "", "27", "", "", "")
+	aggs := facetAggregations(params.facetFilters())
+
+	tests := []struct {
+		name    string
+		want    string
+		notWant string
+	}{
+		{name: "facet_year", want: "metadata.resource", notWant: "metadata.signature_year\""},
+		{name: "facet_resource", want: "metadata.signature_year\"", notWant: "\"metadata.resource\""},
+		{name: "facet_company", want: "metadata.resource", notWant: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := aggs[tt.name].Source()
+			if err != nil {
+				t.Fatalf("Source() error = %v", err)
+			}
+			body, _ := json.Marshal(src)
+			if !strings.Contains(string(body), tt.want) {
+				t.Errorf("%s = %s, want it to contain %s", tt.name, body, tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(string(body), tt.notWant) {
+				t.Errorf("%s = %s, want it to leave out %s", tt.name, body, tt.notWant)
+			}
+		})
+	}
+}
+
+func TestNamedLabel(t *testing.T) {
+	SetUnitNameLoader(func() (map[int]string, error) { return map[int]string{12: "Өмнөговь"}, nil })
+	t.Cleanup(func() { SetUnitNameLoader(nil) })
+
+	tests := []struct {
+		name      string
+		dimension string
+		key       string
+		wantKey   string
+		wantLabel *Label
+	}{
+		{name: "resource", dimension: "resource", key: "41", wantKey: "41", wantLabel: &Label{Mn: "Алт", En: "Gold"}},
+		{name: "contract type", dimension: "contract_type", key: "Concession Agreement", wantKey: "Концессийн гэрээ", wantLabel: &Label{Mn: "Концессийн гэрээ", En: "Concession Agreement"}},
+		{name: "document type", dimension: "document_type", key: "Contract", wantKey: "Гэрээ", wantLabel: &Label{Mn: "Гэрээ", En: "Contract"}},
+		{name: "province", dimension: "province", key: "12", wantKey: "12", wantLabel: &Label{Mn: "Өмнөговь", En: "Өмнөговь"}},
+		{name: "unknown province", dimension: "province", key: "99", wantKey: "99"},
+		{name: "unmapped resource", dimension: "resource", key: "999", wantKey: "999"},
+		{name: "company", dimension: "company", key: "Oyu Tolgoi LLC", wantKey: "Oyu Tolgoi LLC"},
+		{name: "government with a comma", dimension: "government", key: "Уул уурхай, хүнд үйлдвэрийн яам", wantKey: `Уул уурхай\, хүнд үйлдвэрийн яам`, wantLabel: &Label{Mn: "Уул уурхай, хүнд үйлдвэрийн яам", En: "Уул уурхай, хүнд үйлдвэрийн яам"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, label := namedLabel(tt.dimension, tt.key)
+			if key != tt.wantKey || !reflect.DeepEqual(label, tt.wantLabel) {
+				t.Errorf("namedLabel() = %v, %v, want %v, %v", key, label, tt.wantKey, tt.wantLabel)
+			}
+		})
+	}
+}
+
+func TestUnitNameLoader(t *testing.T) {
+	t.Cleanup(func() { SetUnitNameLoader(nil) })
+
+	SetUnitNameLoader(nil)
+	if _, ok := unitName("12"); ok {
+		t.Errorf("unitName() without a loader found a name")
+	}
+
+	calls := 0
+	SetUnitNameLoader(func() (map[int]string, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return map[int]string{12: "Өмнөговь"}, nil
+	})
+	if _, ok := unitName("12"); ok {
+		t.Errorf("unitName() found a name although the loader failed")
+	}
+	for i := 0; i < 2; i++ {
+		if name, ok := unitName("12"); !ok || name != "Өмнөговь" {
+			t.Errorf("unitName() after a failed read = %q, %v, want Өмнөговь", name, ok)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("loader called %d times, want a retry and then the cache", calls)
+	}
+}
+
+func TestFacetLabelFallsBackToKey(t *testing.T) {
+	if key, label := facetLabel("company", "Oyu Tolgoi LLC"); key != "Oyu Tolgoi LLC" || label != (Label{Mn: "Oyu Tolgoi LLC", En: "Oyu Tolgoi LLC"}) {
+		t.Errorf("facetLabel(company) = %v, %v, want the key as label", key, label)
+	}
+	if _, label := facetLabel("resource", "41"); label != (Label{Mn: "Алт", En: "Gold"}) {
+		t.Errorf("facetLabel(resource) label = %v", label)
+	}
+}
@@ -0,0 +1,471 @@
+package queries
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+)
+
+// fakeDocument is a document of a fixture index.
+type fakeDocument struct {
+	ID     string                 `json:"_id"`
+	Source map[string]interface{} `json:"_source"`
+}
+
+// fakeElastic serves the documents of a fixture file as an Elasticsearch
+// index. It evaluates the query and post_filter of a search with the clauses
+// the filters use (bool, exists, match_phrase, term, terms, regexp, match_all)
+// and pages the matches in fixture order. Full-text simple_query_string
+// clauses match every document and function_score clauses match as their
+// query. Searches sorted by _score rank the matches with the rough scoring
+// model of score; other sorts are left out.
+// Top-level terms aggregations without sub-aggregations are counted over
+// the query matches, other aggregations are left out. Counts evaluate the
+// query the same way. The searches it receives are recorded.
+type fakeElastic struct {
+	t        *testing.T
+	docs     []fakeDocument
+	searches []map[string]interface{}
+}
+
+// newFakeElastic starts a fake backend with the documents of the fixture
+// file and points the Elasticsearch environment of the test at it.
+func newFakeElastic(t *testing.T, fixture string) *fakeElastic {
+	data, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeElastic{t: t}
+	if err := json.Unmarshal(data, &f.docs); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(server.Close)
+
+	t.Setenv("ELASTICSEARCH_HOST", server.URL)
+	t.Setenv("ELASTICSEARCH_SECONDARY", "contracts")
+	t.Setenv("ELASTICSEARCH_DOC_MASTER", "master")
+	return f
+}
+
+func (f *fakeElastic) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasSuffix(r.URL.Path, "/_count") {
+		var body map[string]interface{}
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+
+		count := 0
+		for _, doc := range f.docs {
+			if f.matches(doc.Source, body["query"]) {
+				count++
+			}
+		}
+		fmt.Fprintf(w, `{"count": %d}`, count)
+		return
+	}
+	if !strings.HasSuffix(r.URL.Path, "/_search") {
+		fmt.Fprint(w, `{"version": {"number": "5.6.16"}}`)
+		return
+	}
+
+	var body map[string]interface{}
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		f.t.Errorf("fake elastic: invalid search body %s: %v", data, err)
+	}
+	f.searches = append(f.searches, body)
+
+	hits := []interface{}{}
+	var matched []fakeDocument
+	for _, doc := range f.docs {
+		if !f.matches(doc.Source, body["query"]) {
+			continue
+		}
+		matched = append(matched, doc)
+		if f.matches(doc.Source, body["post_filter"]) {
+			hits = append(hits, map[string]interface{}{"_index": "contracts", "_type": "master", "_id": doc.ID, "_source": doc.Source, "_score": f.score(doc.Source, body["query"])})
+		}
+	}
+	if sortedByScore(body["sort"]) {
+		sort.SliceStable(hits, func(i, j int) bool {
+			return hits[i].(map[string]interface{})["_score"].(float64) > hits[j].(map[string]interface{})["_score"].(float64)
+		})
+	}
+	total := len(hits)
+
+	from, size := 0, 10
+	if v, ok := body["from"].(float64); ok {
+		from = int(v)
+	}
+	if v, ok := body["size"].(float64); ok {
+		size = int(v)
+	}
+	hits = hits[min(from, total):min(from+size, total)]
+
+	aggs := map[string]interface{}{}
+	requested, _ := body["aggregations"].(map[string]interface{})
+	for name, agg := range requested {
+		if buckets, ok := f.terms(matched, agg.(map[string]interface{})); ok {
+			aggs[name] = map[string]interface{}{"buckets": buckets}
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"took":         1,
+		"hits":         map[string]interface{}{"total": total, "hits": hits},
+		"aggregations": aggs,
+	})
+}
+
+// terms counts a terms aggregation over the documents, largest count first.
+// It reports false for other aggregations and for terms aggregations with
+// sub-aggregations.
+func (f *fakeElastic) terms(docs []fakeDocument, agg map[string]interface{}) ([]interface{}, bool) {
+	args, ok := agg["terms"].(map[string]interface{})
+	if !ok || agg["aggregations"] != nil {
+		return nil, false
+	}
+
+	included := func(string) bool { return true }
+	switch include := args["include"].(type) {
+	case string:
+		pattern := regexp.MustCompile("^(?:" + strings.ReplaceAll(include, `\ `, " ") + ")$")
+		included = pattern.MatchString
+	case []interface{}:
+		included = func(v string) bool { return contains(include, v) }
+	}
+
+	counts := map[string]int{}
+	for _, doc := range docs {
+		seen := map[string]bool{}
+		for _, v := range fieldValues(doc.Source, args["field"].(string)) {
+			if !seen[v] && included(v) {
+				seen[v] = true
+				counts[v]++
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if size, ok := args["size"].(float64); ok && int(size) < len(keys) {
+		keys = keys[:int(size)]
+	}
+
+	buckets := []interface{}{}
+	for _, k := range keys {
+		buckets = append(buckets, map[string]interface{}{"key": k, "doc_count": counts[k]})
+	}
+	return buckets, true
+}
+
+func contains(values []interface{}, wanted string) bool {
+	for _, v := range values {
+		if fmt.Sprint(v) == wanted {
+			return true
+		}
+	}
+	return false
+}
+
+// ids returns the IDs of the hits of a search result, in order.
+func (f *fakeElastic) ids(res *SearchResponse) []string {
+	ids := []string{}
+	for _, hit := range res.Hits.Hits {
+		ids = append(ids, hit.Id)
+	}
+	return ids
+}
+
+// matches evaluates a query clause against a document source. A missing
+// clause matches everything.
+func (f *fakeElastic) matches(source map[string]interface{}, clause interface{}) bool {
+	if clause == nil {
+		return true
+	}
+
+	query, ok := clause.(map[string]interface{})
+	if !ok || len(query) != 1 {
+		f.t.Fatalf("fake elastic: malformed clause %v", clause)
+	}
+
+	for kind, body := range query {
+		args, _ := body.(map[string]interface{})
+		switch kind {
+		case "match_all":
+			return true
+		case "bool":
+			return f.matchesBool(source, args)
+		case "exists":
+			return len(fieldValues(source, args["field"].(string))) > 0
+		case "function_score":
+			return f.matches(source, args["query"])
+		case "simple_query_string":
+			return true
+		}
+
+		for field, value := range args {
+			values := fieldValues(source, field)
+			switch kind {
+			case "match_phrase":
+				if m, ok := value.(map[string]interface{}); ok {
+					value = m["query"]
+				}
+				for _, v := range values {
+					if strings.Contains(strings.ToLower(v), strings.ToLower(fmt.Sprint(value))) {
+						return true
+					}
+				}
+				return false
+			case "term":
+				return containsValue(values, value)
+			case "regexp":
+				if m, ok := value.(map[string]interface{}); ok {
+					value = m["value"]
+				}
+				// Lucene escapes spaces, Go does not accept the escape.
+				pattern := regexp.MustCompile("^(?:" + strings.ReplaceAll(fmt.Sprint(value), `\ `, " ") + ")$")
+				for _, v := range values {
+					if pattern.MatchString(v) {
+						return true
+					}
+				}
+				return false
+			case "terms":
+				for _, wanted := range value.([]interface{}) {
+					if containsValue(values, wanted) {
+						return true
+					}
+				}
+				return false
+			}
+		}
+
+		f.t.Fatalf("fake elastic: unsupported clause %s", kind)
+	}
+	return false
+}
+
+func (f *fakeElastic) matchesBool(source map[string]interface{}, args map[string]interface{}) bool {
+	clauses := func(name string) []interface{} {
+		switch v := args[name].(type) {
+		case nil:
+			return nil
+		case []interface{}:
+			return v
+		default:
+			return []interface{}{v}
+		}
+	}
+
+	for _, name := range []string{"must", "filter"} {
+		for _, c := range clauses(name) {
+			if !f.matches(source, c) {
+				return false
+			}
+		}
+	}
+	for _, c := range clauses("must_not") {
+		if f.matches(source, c) {
+			return false
+		}
+	}
+
+	should := clauses("should")
+	minimum := 0
+	if len(should) > 0 && len(clauses("must"))+len(clauses("filter")) == 0 {
+		minimum = 1
+	}
+	switch v := args["minimum_should_match"].(type) {
+	case float64:
+		minimum = int(v)
+	case string:
+		fmt.Sscan(v, &minimum)
+	}
+
+	matched := 0
+	for _, c := range should {
+		if f.matches(source, c) {
+			matched++
+		}
+	}
+	return matched >= minimum
+}
+
+// sortedByScore reports whether a search sorts by _score first.
+func sortedByScore(sorts interface{}) bool {
+	list, _ := sorts.([]interface{})
+	if len(list) == 0 {
+		return false
+	}
+	switch first := list[0].(type) {
+	case string:
+		return first == "_score"
+	case map[string]interface{}:
+		_, ok := first["_score"]
+		return ok
+	}
+	return false
+}
+
+// score roughly models the Elasticsearch score of a document, enough to
+// compare rankings: every word of a simple_query_string adds the boost of
+// each field holding it, bool clauses add up the scores of must and should,
+// and function_score multiplies its query's score by the sum of its weight,
+// gauss and filtered weight functions. Other clauses score 0.
+func (f *fakeElastic) score(source map[string]interface{}, clause interface{}) float64 {
+	query, _ := clause.(map[string]interface{})
+	for kind, body := range query {
+		args, _ := body.(map[string]interface{})
+		switch kind {
+		case "bool":
+			total := 0.0
+			for _, name := range []string{"must", "should"} {
+				switch v := args[name].(type) {
+				case []interface{}:
+					for _, c := range v {
+						total += f.score(source, c)
+					}
+				case map[string]interface{}:
+					total += f.score(source, v)
+				}
+			}
+			return total
+		case "simple_query_string":
+			return textScore(source, args)
+		case "function_score":
+			multiplier := 0.0
+			functions, _ := args["functions"].([]interface{})
+			for _, fn := range functions {
+				multiplier += f.functionScore(source, fn.(map[string]interface{}))
+			}
+			return f.score(source, args["query"]) * multiplier
+		}
+	}
+	return 0
+}
+
+// textScore adds the boost of every field holding a word of the query.
+func textScore(source map[string]interface{}, args map[string]interface{}) float64 {
+	isWordRune := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }
+	words := strings.FieldsFunc(strings.ToLower(fmt.Sprint(args["query"])), isWordRune)
+
+	total := 0.0
+	fields, _ := args["fields"].([]interface{})
+	for _, field := range fields {
+		name, boost := fmt.Sprint(field), 1.0
+		if i := strings.Index(name, "^"); i >= 0 {
+			fmt.Sscan(name[i+1:], &boost)
+			name = name[:i]
+		}
+
+		held := map[string]bool{}
+		for _, value := range fieldValues(source, name) {
+			for _, word := range strings.FieldsFunc(strings.ToLower(value), isWordRune) {
+				held[word] = true
+			}
+		}
+		for _, word := range words {
+			if held[word] {
+				total += boost
+			}
+		}
+	}
+	return total
+}
+
+// functionScore evaluates one function of a function_score query.
+func (f *fakeElastic) functionScore(source map[string]interface{}, fn map[string]interface{}) float64 {
+	weight := 1.0
+	if w, ok := fn["weight"].(float64); ok {
+		weight = w
+	}
+	if filter, ok := fn["filter"]; ok && !f.matches(source, filter) {
+		return 0
+	}
+
+	gauss, ok := fn["gauss"].(map[string]interface{})
+	if !ok {
+		return weight
+	}
+	for field, body := range gauss {
+		args := body.(map[string]interface{})
+		values := fieldValues(source, field)
+		if len(values) == 0 {
+			return 0
+		}
+		date, err := time.Parse("2006-01-02", values[0])
+		if err != nil {
+			f.t.Fatalf("fake elastic: gauss over %s = %q: %v", field, values[0], err)
+		}
+
+		days := func(name string) float64 {
+			var n float64
+			if v, ok := args[name].(string); ok {
+				fmt.Sscanf(v, "%gd", &n)
+			}
+			return n
+		}
+		distance := math.Max(0, math.Abs(time.Since(date).Hours()/24)-days("offset"))
+		scale := days("scale")
+		sigma2 := -scale * scale / (2 * math.Log(args["decay"].(float64)))
+		return weight * math.Exp(-distance*distance/(2*sigma2))
+	}
+	return 0
+}
+
+// fieldValues returns the values of a dotted field, flattening arrays. The
+// .keyword sub-field reads the field itself.
+func fieldValues(source map[string]interface{}, field string) []string {
+	var values []string
+	var walk func(value interface{}, path []string)
+	walk = func(value interface{}, path []string) {
+		switch v := value.(type) {
+		case nil:
+		case []interface{}:
+			for _, item := range v {
+				walk(item, path)
+			}
+		case map[string]interface{}:
+			if len(path) > 0 {
+				walk(v[path[0]], path[1:])
+			}
+		default:
+			if len(path) == 0 {
+				values = append(values, fmt.Sprint(v))
+			}
+		}
+	}
+	walk(source, strings.Split(strings.TrimSuffix(field, ".keyword"), "."))
+	return values
+}
+
+func containsValue(values []string, wanted interface{}) bool {
+	for _, v := range values {
+		if v == fmt.Sprint(wanted) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package queries
+
+import (
+	"iltodgeree/api/internal/correction"
+	"strings"
+	"time"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// dimension describes a filterable facet of the contract corpus.
+type dimension struct {
+	name  string // Public name used in query parameters and responses
+	field string // Keyword field used for aggregations
+}
+
+// dimensions lists every facet SearchV2 can filter and aggregate on.
+// The order is preserved when building queries and responses.
+var dimensions = []dimension{
+	{name: "year", field: "metadata.signature_year.keyword"},
+	{name: "resource", field: "metadata.resource.keyword"},
+	{name: "contract_type", field: "metadata.contract_type.keyword"},
+	{name: "document_type", field: "metadata.document_type.keyword"},
+	{name: "province", field: "metadata.provinces.province.keyword"},
+	{name: "district", field: "metadata.provinces.district.keyword"},
+	{name: "company", field: "metadata.company_name.keyword"},
+	{name: "government", field: "metadata.government_entity.entity.keyword"},
+	{name: "annotation_category", field: "annotations_category.keyword"},
+}
+
+// fixedFilters lists the filter groups that are not facets: the date ranges
+// and whether a contract is annotated. They are always applied, including
+// inside facet aggregations.
+var fixedFilters = []string{"signature_date", "created_at", "annotated"}
+
+// dateLayout is the ISO date format accepted by the date range filters.
+const dateLayout = "2006-01-02"
+
+// facetFilters builds the filter clauses of the search, grouped by the
+// dimension they restrict.
+func (s *SearchParams) facetFilters() map[string][]elastic.Query {
+	filters := map[string][]elastic.Query{}
+
+	if len(s.years) != 0 {
+		filters["year"] = append(filters["year"], elastic.NewTermsQuery("metadata.signature_year", s.years...))
+	}
+
+	if len(s.resources) != 0 {
+		filters["resource"] = append(filters["resource"], elastic.NewTermsQuery("metadata.resource", s.resources...))
+	}
+
+	if s.province != "" {
+		filters["province"] = append(filters["province"], elastic.NewTermsQuery("metadata.provinces.province", s.province))
+	}
+
+	if len(s.district) > 0 {
+		filters["district"] = append(filters["district"], elastic.NewTermsQuery("metadata.provinces.district", s.district...))
+	}
+
+	for _, documentType := range s.documentTypes {
+		filters["document_type"] = append(filters["document_type"], elastic.NewTermsQuery("metadata.document_type.keyword", correction.DocumentTypesReverse[documentType.(string)]))
+	}
+
+	for _, t := range s.contractTypes {
+		filters["contract_type"] = append(filters["contract_type"], elastic.NewTermsQuery("metadata.contract_type.keyword", correction.ContractTypesReverse[t.(string)]))
+	}
+
+	if len(s.governments) > 0 {
+		filters["government"] = append(filters["government"], s.nameFilters("metadata.government_entity.entity.keyword", "metadata.government_entity.entity", s.governments))
+	}
+
+	if len(s.companies) > 0 {
+		filters["company"] = append(filters["company"], s.nameFilters("metadata.company_name.keyword", "metadata.company_name", s.companies))
+	}
+
+	if len(s.annotationCategory) > 0 {
+		categories := []elastic.Query{}
+		for _, c := range s.annotationCategory {
+			categories = append(categories, elastic.NewMatchPhraseQuery("annotations_category", c))
+		}
+		if s.annotationMatch == "all" {
+			filters["annotation_category"] = append(filters["annotation_category"], categories...)
+		} else {
+			filters["annotation_category"] = append(filters["annotation_category"], elastic.NewBoolQuery().Should(categories...).MinimumNumberShouldMatch(1))
+		}
+	}
+
+	if s.annotated != nil {
+		annotated := elastic.NewExistsQuery("annotations_string")
+		if *s.annotated {
+			filters["annotated"] = append(filters["annotated"], annotated)
+		} else {
+			filters["annotated"] = append(filters["annotated"], elastic.NewBoolQuery().MustNot(annotated))
+		}
+	}
+
+	if s.createdAfter != nil {
+		filters["created_at"] = append(filters["created_at"], elastic.NewRangeQuery("created_at").Gt(s.createdAfter.Format(time.RFC3339)))
+	}
+
+	if r := dateRange("metadata.signature_date", s.signedFrom, s.signedTo); r != nil {
+		filters["signature_date"] = append(filters["signature_date"], r)
+	}
+
+	if r := dateRange("created_at", s.createdFrom, s.createdTo); r != nil {
+		filters["created_at"] = append(filters["created_at"], r)
+	}
+
+	return filters
+}
+
+// excludeFilters builds the must_not clauses of the search. Each excluded
+// dimension gets one clause matching any of its values, translated the same
+// way as the include filters.
+func (s *SearchParams) excludeFilters() []elastic.Query {
+	filters := []elastic.Query{}
+
+	for _, d := range dimensions {
+		values := s.excludes[d.name]
+		if len(values) == 0 {
+			continue
+		}
+
+		switch d.name {
+		case "year":
+			filters = append(filters, elastic.NewTermsQuery("metadata.signature_year", values...))
+		case "resource":
+			filters = append(filters, elastic.NewTermsQuery("metadata.resource", values...))
+		case "province":
+			filters = append(filters, elastic.NewTermsQuery("metadata.provinces.province", values...))
+		case "district":
+			filters = append(filters, elastic.NewTermsQuery("metadata.provinces.district", values...))
+		case "document_type":
+			filters = append(filters, elastic.NewTermsQuery("metadata.document_type.keyword", translate(values, correction.DocumentTypesReverse)...))
+		case "contract_type":
+			filters = append(filters, elastic.NewTermsQuery("metadata.contract_type.keyword", translate(values, correction.ContractTypesReverse)...))
+		case "government":
+			for _, v := range values {
+				filters = append(filters, s.nameFilter("metadata.government_entity.entity.keyword", "metadata.government_entity.entity", v.(string)))
+			}
+		case "company":
+			for _, v := range values {
+				filters = append(filters, s.nameFilter("metadata.company_name.keyword", "metadata.company_name", v.(string)))
+			}
+		case "annotation_category":
+			for _, v := range values {
+				filters = append(filters, elastic.NewMatchPhraseQuery("annotations_category", v))
+			}
+		}
+	}
+
+	return filters
+}
+
+// nameFilter matches a company or government name, with its spelling
+// variants unless transliteration is off. A value ending in * matches the
+// names starting with the rest of it.
+func (s *SearchParams) nameFilter(keywordField string, textField string, value string) elastic.Query {
+	if prefix, ok := strings.CutSuffix(value, "*"); ok {
+		return prefixFilter(keywordField, prefix, s.transliterate)
+	}
+	if s.transliterate {
+		return expandedFilter(keywordField, textField, value)
+	}
+	return elastic.NewTermsQuery(keywordField, value)
+}
+
+// nameFilters matches any of the company or government names.
+func (s *SearchParams) nameFilters(keywordField string, textField string, values []interface{}) elastic.Query {
+	if len(values) == 1 {
+		return s.nameFilter(keywordField, textField, values[0].(string))
+	}
+
+	should := []elastic.Query{}
+	for _, v := range values {
+		should = append(should, s.nameFilter(keywordField, textField, v.(string)))
+	}
+	return elastic.NewBoolQuery().Should(should...).MinimumNumberShouldMatch(1)
+}
+
+// namePrefixLead skips the quotes, brackets and spaces a stored name may
+// start with, so prefixes match the normalised name.
+const namePrefixLead = "[^0-9a-zA-Zа-яА-ЯёЁөӨүҮ]*"
+
+// prefixFilter matches the keyword values that start with prefix, ignoring
+// case, leading punctuation and, with transliteration, the script. The
+// optional lead and the case folding keep the regexp from seeking to the
+// prefix in the terms index, so it is checked against every distinct name
+// of the field. That is cheap for the few thousand company and government
+// names; a normalised sub-field with a prefix query would be needed if they
+// grew much larger.
+func prefixFilter(keywordField string, prefix string, transliterate bool) elastic.Query {
+	variants := spellings(prefix)
+	if len(variants) == 0 {
+		return elastic.NewExistsQuery(keywordField)
+	}
+	if !transliterate {
+		variants = variants[:1]
+	}
+
+	var patterns []string
+	for _, sp := range variants {
+		patterns = append(patterns, sp.lucene())
+	}
+	return elastic.NewRegexpQuery(keywordField, namePrefixLead+"("+strings.Join(patterns, "|")+").*")
+}
+
+// translate maps Mongolian filter values to the English values stored in
+// the index.
+func translate(values []interface{}, reverse correction.Map) []interface{} {
+	translated := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		translated = append(translated, reverse[v.(string)])
+	}
+	return translated
+}
+
+// flattenFilters joins the grouped filters in dimension order, leaving out
+// the dimension named by except (pass "" to keep all of them).
+func flattenFilters(grouped map[string][]elastic.Query, except string) []elastic.Query {
+	filters := []elastic.Query{}
+	for _, d := range dimensions {
+		if d.name == except {
+			continue
+		}
+		filters = append(filters, grouped[d.name]...)
+	}
+	for _, name := range fixedFilters {
+		filters = append(filters, grouped[name]...)
+	}
+	return filters
+}
+
+// dateRange builds an inclusive range filter over whole days, or nil when
+// both bounds are open.
+func dateRange(field string, from *time.Time, to *time.Time) elastic.Query {
+	if from == nil && to == nil {
+		return nil
+	}
+
+	r := elastic.NewRangeQuery(field)
+	if from != nil {
+		r = r.Gte(from.Format(dateLayout))
+	}
+	if to != nil {
+		// Rounding up to the end of the day keeps the upper bound inclusive
+		// for timestamps.
+		r = r.Lte(to.Format(dateLayout) + "||/d")
+	}
+	return r
+}
@@ -0,0 +1,105 @@
+package queries
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func searchFixture(t *testing.T, f *fakeElastic, values url.Values) []string {
+	params, err := SearchParamsFromValues(values)
+	if err != nil {
+		t.Fatalf("SearchParamsFromValues(%v) error = %v", values, err)
+	}
+
+	res, e := SearchV2(params)
+	if *e != nil {
+		t.Fatalf("SearchV2(%v) error = %v", values, *e)
+	}
+	return f.ids(res)
+}
+
+func TestSearchAnnotated(t *testing.T) {
+	f := newFakeElastic(t, "testdata/annotated_contracts.json")
+
+	tests := []struct {
+		values url.Values
+		want   string
+	}{
+		{url.Values{}, "1,2,3,4,5"},
+		{url.Values{"annotated": {"true"}}, "1,2,3"},
+		{url.Values{"annotated": {"false"}}, "4,5"},
+		{url.Values{"annotated": {"true"}, "facets": {"true"}}, "1,2,3"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(searchFixture(t, f, tt.values), ","); got != tt.want {
+			t.Errorf("search %v = %s, want %s", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestSearchAnnotationCategories(t *testing.T) {
+	f := newFakeElastic(t, "testdata/annotated_contracts.json")
+
+	tests := []struct {
+		values url.Values
+		want   string
+	}{
+		{url.Values{"annotation_category": {"Royalties"}}, "1,2"},
+		{url.Values{"annotation_category": {"Royalties,Local content"}}, "1,2,3"},
+		{url.Values{"annotation_category": {"Royalties,Local content"}, "annotation_category_match": {"any"}}, "1,2,3"},
+		{url.Values{"annotation_category": {"Royalties,Environmental protection"}, "annotation_category_match": {"all"}}, "1"},
+		{url.Values{"annotation_category": {"Royalties,Local content"}, "annotation_category_match": {"all"}}, ""},
+		{url.Values{"annotation_category": {"Royalties"}, "annotated": {"false"}}, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(searchFixture(t, f, tt.values), ","); got != tt.want {
+			t.Errorf("search %v = %s, want %s", tt.values, got, tt.want)
+		}
+	}
+
+	if _, err := SearchParamsFromValues(url.Values{"annotation_category_match": {"most"}}); err == nil {
+		t.Errorf("annotation_category_match=most: error = nil, want an error")
+	}
+}
+
+func TestSearchCompaniesAndGovernments(t *testing.T) {
+	f := newFakeElastic(t, "testdata/company_contracts.json")
+
+	tests := []struct {
+		values url.Values
+		want   string
+	}{
+		{url.Values{"company": {"Oyu Tolgoi LLC"}, "transliterate": {"false"}}, "3,4"},
+		{url.Values{"company": {"Oyu Tolgoi LLC,Монголын Эрдэнэс ХХК"}, "transliterate": {"false"}}, "3,4,5"},
+		{url.Values{"company": {"Erdenes*"}}, "1,2,3"},
+		{url.Values{"company": {"erdenes*"}, "transliterate": {"false"}}, "3"},
+		{url.Values{"company": {"Эрдэнэс Т*,Oyu*"}}, "1,3,4"},
+		{url.Values{"company": {"Erdenes*"}, "exclude_company": {"Erdenes Oyu*"}}, "1,2"},
+		{url.Values{"exclude_company": {"Oyu Tolgoi LLC,Эрдэнэс*"}, "transliterate": {"false"}}, "5"},
+		{url.Values{"government": {`Уул уурхай\, хүнд үйлдвэрийн яам,Сангийн яам`}, "transliterate": {"false"}}, "1,2,5"},
+		{url.Values{"government": {"mongolian*"}}, "3,4"},
+		{url.Values{"exclude_government": {"Сангийн*"}}, "1,3,4"},
+		{url.Values{"q": {"company:Erdenes*"}}, "1,2,3"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(searchFixture(t, f, tt.values), ","); got != tt.want {
+			t.Errorf("search %v = %s, want %s", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestSplitNames(t *testing.T) {
+	got := splitNames(` Oyu Tolgoi LLC, Уул уурхай\, хүнд үйлдвэрийн яам,,Erdenes* `)
+	want := []string{"Oyu Tolgoi LLC", "Уул уурхай, хүнд үйлдвэрийн яам", "Erdenes*"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("splitNames() = %q, want %q", got, want)
+	}
+
+	// Facet keys are passed back as filters unchanged.
+	name := "Уул уурхай, хүнд үйлдвэрийн яам"
+	key, _ := namedLabel("government", name)
+	if got := splitNames(key + ",Сангийн яам"); len(got) != 2 || got[0] != name {
+		t.Errorf("splitNames() of the facet key = %q, want %q first", got, name)
+	}
+}
@@ -0,0 +1,279 @@
+package queries
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	appcontext "iltodgeree/api/internal/app_context"
+	"os"
+	"sort"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// graphNodeTypes lists the node types with the field their values are
+// read from.
+var graphNodeTypes = []struct{ name, field string }{
+	{"company", "metadata.company_name.keyword"},
+	{"government", "metadata.government_entity.entity.keyword"},
+	{"resource", "metadata.resource.keyword"},
+}
+
+// GraphNode is a company, government entity or resource. Count is the
+// number of contracts it appears in.
+type GraphNode struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Count int64  `json:"count"`
+}
+
+// GraphEdge links two nodes that appear in the same contracts. Weight is
+// the number of those contracts.
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Weight int64  `json:"weight"`
+}
+
+// Graph is the co-occurrence graph of companies, government entities and
+// resources.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// ContractGraph builds the graph of the companies, government entities and
+// resources that appear together in the contracts matching the search
+// parameters. Each type keeps its size most frequent values, and each node
+// its size strongest partners per type; edges to values that were left out
+// are dropped.
+//
+// Parameters:
+//   - params: Search parameters selecting the contracts, as for /api/search
+//   - size: Maximum number of nodes per type
+//
+// Returns:
+//   - *Graph: Nodes ordered by type and count, edges by weight
+//   - error: Error if the search fails
+func ContractGraph(params *SearchParams, size int) (*Graph, error) {
+	client, err := appcontext.ElasticInstance.GetV5()
+	if err != nil {
+		panic(err)
+	}
+
+	search := client.Search().
+		Index(os.Getenv("ELASTICSEARCH_SECONDARY")).
+		Type(os.Getenv("ELASTICSEARCH_DOC_MASTER")).
+		Query(params.filterQuery(flattenFilters(params.facetFilters(), ""))).
+		Size(0)
+
+	for i, t := range graphNodeTypes {
+		agg := elastic.NewTermsAggregation().Field(t.field).Size(size)
+		// Each pair is read once, from the type listed first. A node reads
+		// its size strongest partners of each type, which keeps the buckets
+		// at 3*size*size; weaker edges to kept nodes may be dropped.
+		for _, other := range graphNodeTypes[i+1:] {
+			agg = agg.SubAggregation(other.name, elastic.NewTermsAggregation().Field(other.field).Size(size))
+		}
+		search = search.Aggregation(t.name, agg)
+	}
+
+	result, err := search.Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search: %v", err)
+	}
+
+	return buildGraph(result.Aggregations), nil
+}
+
+// buildGraph reads the nodes and edges from the aggregation result.
+func buildGraph(aggs elastic.Aggregations) *Graph {
+	graph := &Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	nodes := map[string]bool{}
+
+	for _, t := range graphNodeTypes {
+		terms, found := aggs.Terms(t.name)
+		if !found {
+			continue
+		}
+		for _, bucket := range terms.Buckets {
+			key := fmt.Sprint(bucket.Key)
+			label := key
+			if t.name == "resource" {
+				_, l := facetLabel("resource", key)
+				label = l.Mn
+			}
+			node := GraphNode{ID: t.name + ":" + key, Type: t.name, Key: key, Label: label, Count: bucket.DocCount}
+			graph.Nodes = append(graph.Nodes, node)
+			nodes[node.ID] = true
+		}
+	}
+
+	for i, t := range graphNodeTypes {
+		terms, found := aggs.Terms(t.name)
+		if !found {
+			continue
+		}
+		for _, bucket := range terms.Buckets {
+			source := t.name + ":" + fmt.Sprint(bucket.Key)
+			for _, other := range graphNodeTypes[i+1:] {
+				partners, found := bucket.Aggregations.Terms(other.name)
+				if !found {
+					continue
+				}
+				for _, partner := range partners.Buckets {
+					target := other.name + ":" + fmt.Sprint(partner.Key)
+					if nodes[target] {
+						graph.Edges = append(graph.Edges, GraphEdge{Source: source, Target: target, Weight: partner.DocCount})
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		return graph.Edges[i].Weight > graph.Edges[j].Weight
+	})
+	return graph
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfNode struct {
+	ID        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID     int    `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+	Weight int64  `xml:"weight,attr"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfDocument struct {
+	XMLName xml.Name `xml:"gexf"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Graph   struct {
+		Mode            string `xml:"mode,attr"`
+		DefaultEdgeType string `xml:"defaultedgetype,attr"`
+		Attributes      struct {
+			Class      string          `xml:"class,attr"`
+			Attributes []gexfAttribute `xml:"attribute"`
+		} `xml:"attributes"`
+		Nodes []gexfNode `xml:"nodes>node"`
+		Edges []gexfEdge `xml:"edges>edge"`
+	} `xml:"graph"`
+}
+
+// GEXF renders the graph in the GEXF 1.2 format read by Gephi, with the
+// node type and count as node attributes.
+func (g *Graph) GEXF() ([]byte, error) {
+	doc := gexfDocument{XMLNS: "http://www.gexf.net/1.2draft", Version: "1.2"}
+	doc.Graph.Mode = "static"
+	doc.Graph.DefaultEdgeType = "undirected"
+	doc.Graph.Attributes.Class = "node"
+	doc.Graph.Attributes.Attributes = []gexfAttribute{
+		{ID: "type", Title: "type", Type: "string"},
+		{ID: "count", Title: "count", Type: "long"},
+	}
+
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{
+			ID:        n.ID,
+			Label:     n.Label,
+			AttValues: []gexfAttValue{{For: "type", Value: n.Type}, {For: "count", Value: fmt.Sprint(n.Count)}},
+		})
+	}
+	for i, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{ID: i, Source: e.Source, Target: e.Target, Weight: e.Weight})
+	}
+
+	return marshalXML(doc)
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+// GraphML renders the graph in the GraphML format, with the label, type
+// and count of the nodes and the weight of the edges as data keys.
+func (g *Graph) GraphML() ([]byte, error) {
+	doc := graphMLDocument{XMLNS: "http://graphml.graphdrawing.org/xmlns"}
+	doc.Keys = []graphMLKey{
+		{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+		{ID: "type", For: "node", AttrName: "type", AttrType: "string"},
+		{ID: "count", For: "node", AttrName: "count", AttrType: "long"},
+		{ID: "weight", For: "edge", AttrName: "weight", AttrType: "long"},
+	}
+	doc.Graph.ID = "contracts"
+	doc.Graph.EdgeDefault = "undirected"
+
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID:   n.ID,
+			Data: []graphMLData{{Key: "label", Value: n.Label}, {Key: "type", Value: n.Type}, {Key: "count", Value: fmt.Sprint(n.Count)}},
+		})
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: e.Source,
+			Target: e.Target,
+			Data:   []graphMLData{{Key: "weight", Value: fmt.Sprint(e.Weight)}},
+		})
+	}
+
+	return marshalXML(doc)
+}
+
+func marshalXML(doc interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
@@ -0,0 +1,105 @@
+package queries
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+func testGraph(t *testing.T) *Graph {
+	var aggs elastic.Aggregations
+	err := json.Unmarshal([]byte(`{
+		"company": {"buckets": [
+			{"key": "Oyu Tolgoi LLC", "doc_count": 4,
+				"government": {"buckets": [{"key": "Mongolian Government", "doc_count": 3}, {"key": "Left Out", "doc_count": 1}]},
+				"resource": {"buckets": [{"key": "30", "doc_count": 4}]}}
+		]},
+		"government": {"buckets": [
+			{"key": "Mongolian Government", "doc_count": 5,
+				"resource": {"buckets": [{"key": "30", "doc_count": 2}, {"key": "41", "doc_count": 5}]}}
+		]},
+		"resource": {"buckets": [{"key": "41", "doc_count": 6}, {"key": "30", "doc_count": 4}]}
+	}`), &aggs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buildGraph(aggs)
+}
+
+func TestBuildGraph(t *testing.T) {
+	g := testGraph(t)
+
+	var nodes []string
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n.ID)
+	}
+	if strings.Join(nodes, ",") != "company:Oyu Tolgoi LLC,government:Mongolian Government,resource:41,resource:30" {
+		t.Errorf("nodes = %v", nodes)
+	}
+	if g.Nodes[2].Label != "Алт" || g.Nodes[2].Count != 6 {
+		t.Errorf("resource node = %+v, want Алт with 6 contracts", g.Nodes[2])
+	}
+
+	want := []GraphEdge{
+		{"government:Mongolian Government", "resource:41", 5},
+		{"company:Oyu Tolgoi LLC", "resource:30", 4},
+		{"company:Oyu Tolgoi LLC", "government:Mongolian Government", 3},
+		{"government:Mongolian Government", "resource:30", 2},
+	}
+	if len(g.Edges) != len(want) {
+		t.Fatalf("edges = %v, want %v", g.Edges, want)
+	}
+	for i := range want {
+		if g.Edges[i] != want[i] {
+			t.Errorf("edge %d = %v, want %v", i, g.Edges[i], want[i])
+		}
+	}
+}
+
+func TestContractGraphPartnerSize(t *testing.T) {
+	f := newFakeElastic(t, "testdata/company_contracts.json")
+
+	if _, err := ContractGraph(&SearchParams{}, 20); err != nil {
+		t.Fatalf("ContractGraph() error = %v", err)
+	}
+
+	aggs, _ := json.Marshal(f.searches[0]["aggregations"])
+	if got := strings.Count(string(aggs), `"size":20`); got != 6 {
+		t.Errorf("aggregations = %s, want 3 node and 3 partner aggregations of size 20", aggs)
+	}
+}
+
+func TestGraphExports(t *testing.T) {
+	g := testGraph(t)
+
+	gexf, err := g.GEXF()
+	if err != nil {
+		t.Fatalf("GEXF() error = %v", err)
+	}
+	var doc gexfDocument
+	if err := xml.Unmarshal(gexf, &doc); err != nil {
+		t.Fatalf("GEXF() is not valid XML: %v", err)
+	}
+	if len(doc.Graph.Nodes) != 4 || len(doc.Graph.Edges) != 4 || doc.Graph.Edges[0].Weight != 5 {
+		t.Errorf("GEXF() = %s", gexf)
+	}
+	if !strings.Contains(string(gexf), `<node id="resource:41" label="Алт">`) {
+		t.Errorf("GEXF() = %s, want labelled resource nodes", gexf)
+	}
+
+	graphML, err := g.GraphML()
+	if err != nil {
+		t.Fatalf("GraphML() error = %v", err)
+	}
+	if err := xml.Unmarshal(graphML, &graphMLDocument{}); err != nil {
+		t.Fatalf("GraphML() is not valid XML: %v", err)
+	}
+	for _, want := range []string{`<graph id="contracts" edgedefault="undirected">`, `<edge source="government:Mongolian Government" target="resource:41">`, `<data key="weight">5</data>`} {
+		if !strings.Contains(string(graphML), want) {
+			t.Errorf("GraphML() = %s, want it to contain %s", graphML, want)
+		}
+	}
+}
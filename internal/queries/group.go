@@ -0,0 +1,141 @@
+package queries
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// groupFields maps the group_by modes to the source field holding the group
+// key. parent_id is document.Contract's ParentID: contracts are indexed with
+// their table columns at the top level of the document, like the created_at
+// that GetLatestContracts sorts on, so an amendment, its annexes and its
+// translation group with their parent. The mapping lives with the indexer,
+// not in this repository; a document without parent_id forms its own group.
+var groupFields = map[string]string{
+	"project": "metadata.project_title",
+	"parent":  "parent_id",
+}
+
+// maxGroupedHits caps the hits read to form groups (the index's
+// max_result_window). Hits past it are left out of the groups.
+var maxGroupedHits = 10000
+
+// maxGroupRelated caps the related documents listed per group.
+var maxGroupRelated = 50
+
+// SearchGroup is a set of related documents collapsed into one result.
+type SearchGroup struct {
+	Key     string               `json:"key"`
+	Count   int                  `json:"count"`
+	TopHit  *elastic.SearchHit   `json:"top_hit"`
+	Related []*elastic.SearchHit `json:"related"`
+}
+
+// SetGroupBy collapses the hits by "project" or "parent". Paging with size
+// and from then counts groups instead of hits.
+func (s *SearchParams) SetGroupBy(groupBy string) error {
+	if _, ok := groupFields[groupBy]; !ok && groupBy != "" {
+		return fmt.Errorf("group_by must be project or parent")
+	}
+	s.groupBy = groupBy
+	return nil
+}
+
+// groupKey returns the group of a hit. Documents without a project or
+// parent form a group of their own, keyed by their ID.
+func groupKey(groupBy string, hit *elastic.SearchHit) string {
+	var doc struct {
+		Metadata struct {
+			ProjectTitle interface{} `json:"project_title"`
+		} `json:"metadata"`
+		ParentID interface{} `json:"parent_id"`
+	}
+	if hit.Source != nil {
+		_ = json.Unmarshal(*hit.Source, &doc)
+	}
+
+	value := doc.Metadata.ProjectTitle
+	if groupBy == "parent" {
+		value = doc.ParentID
+	}
+
+	for _, key := range stringValues(value) {
+		if key != "" && key != "0" {
+			return key
+		}
+	}
+	return hit.Id
+}
+
+// groupHitIDs runs the search for IDs only and groups them, keeping the
+// search order. The first ID of each group is its top hit. It also returns
+// the total hit count and whether the hits were cut at maxGroupedHits.
+func groupHitIDs(search *elastic.SearchService, groupBy string) ([][]string, int64, bool, error) {
+	result, err := search.
+		FetchSourceContext(elastic.NewFetchSourceContext(true).Include(groupFields[groupBy])).
+		From(0).
+		Size(maxGroupedHits).
+		Do(context.Background())
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	capped := result.Hits.TotalHits > int64(len(result.Hits.Hits))
+	return groupIDs(groupBy, result.Hits.Hits), result.Hits.TotalHits, capped, nil
+}
+
+func groupIDs(groupBy string, hits []*elastic.SearchHit) [][]string {
+	groups := [][]string{}
+	index := map[string]int{}
+	for _, hit := range hits {
+		key := groupKey(groupBy, hit)
+		if i, ok := index[key]; ok {
+			groups[i] = append(groups[i], hit.Id)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, []string{hit.Id})
+	}
+	return groups
+}
+
+// pageGroups returns one page of groups and the IDs to fetch for it.
+func pageGroups(groups [][]string, from int, size int) ([][]string, []string) {
+	from = min(max(from, 0), len(groups))
+	to := min(from+max(size, 0), len(groups))
+	page := groups[from:to]
+
+	ids := []string{}
+	for _, group := range page {
+		ids = append(ids, group[:min(len(group), maxGroupRelated+1)]...)
+	}
+	return page, ids
+}
+
+// buildGroups assembles the fetched hits into the groups of the page.
+func buildGroups(groupBy string, page [][]string, hits []*elastic.SearchHit) []SearchGroup {
+	byID := map[string]*elastic.SearchHit{}
+	for _, hit := range hits {
+		byID[hit.Id] = hit
+	}
+
+	groups := []SearchGroup{}
+	for _, ids := range page {
+		top, ok := byID[ids[0]]
+		if !ok {
+			continue
+		}
+
+		group := SearchGroup{Key: groupKey(groupBy, top), Count: len(ids), TopHit: top, Related: []*elastic.SearchHit{}}
+		for _, id := range ids[1:] {
+			if hit, ok := byID[id]; ok {
+				group.Related = append(group.Related, hit)
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
@@ -0,0 +1,127 @@
+package queries
+
+import (
+	"encoding/json"
+	appcontext "iltodgeree/api/internal/app_context"
+	"iltodgeree/api/internal/document"
+	"strings"
+	"testing"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+func groupFixture() []*elastic.SearchHit {
+	hit := func(id string, source string) *elastic.SearchHit {
+		raw := json.RawMessage(source)
+		return &elastic.SearchHit{Id: id, Source: &raw}
+	}
+	return []*elastic.SearchHit{
+		hit("3", `{"parent_id": 1, "metadata": {"project_title": "Oyu Tolgoi"}}`),
+		hit("5", `{"parent_id": 0, "metadata": {"project_title": ""}}`),
+		hit("1", `{"parent_id": 0, "metadata": {"project_title": "Oyu Tolgoi"}}`),
+		hit("4", `{"parent_id": 1, "metadata": {"project_title": "Tavan Tolgoi"}}`),
+		hit("6", `{"metadata": {}}`),
+	}
+}
+
+func TestGroupIDs(t *testing.T) {
+	tests := []struct {
+		groupBy string
+		want    string
+	}{
+		{groupBy: "parent", want: "3,1,4 5 6"},
+		{groupBy: "project", want: "3,1 5 4 6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.groupBy, func(t *testing.T) {
+			var got []string
+			for _, group := range groupIDs(tt.groupBy, groupFixture()) {
+				got = append(got, strings.Join(group, ","))
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("groupIDs(%s) = %v, want %s", tt.groupBy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupKeyReadsContractParentID(t *testing.T) {
+	data, err := json.Marshal(document.Contract{Id: 3, ParentID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := json.RawMessage(data)
+	if got := groupKey("parent", &elastic.SearchHit{Id: "3", Source: &raw}); got != "1" {
+		t.Errorf("groupKey(parent) = %s, want the ParentID 1", got)
+	}
+}
+
+func TestPageGroups(t *testing.T) {
+	groups := groupIDs("project", groupFixture())
+
+	page, ids := pageGroups(groups, 0, 2)
+	if len(page) != 2 || strings.Join(ids, ",") != "3,1,5" {
+		t.Errorf("pageGroups(0, 2) = %v, %v", page, ids)
+	}
+
+	page, ids = pageGroups(groups, 3, 2)
+	if len(page) != 1 || strings.Join(ids, ",") != "6" {
+		t.Errorf("pageGroups(3, 2) = %v, %v", page, ids)
+	}
+
+	page, ids = pageGroups(groups, 10, 2)
+	if len(page) != 0 || len(ids) != 0 {
+		t.Errorf("pageGroups(10, 2) = %v, %v", page, ids)
+	}
+}
+
+func TestBuildGroups(t *testing.T) {
+	hits := groupFixture()
+	page, _ := pageGroups(groupIDs("parent", hits), 0, 10)
+	groups := buildGroups("parent", page, hits)
+
+	if len(groups) != 3 {
+		t.Fatalf("buildGroups() returned %d groups, want 3", len(groups))
+	}
+	first := groups[0]
+	if first.Key != "1" || first.Count != 3 || first.TopHit.Id != "3" || len(first.Related) != 2 || first.Related[1].Id != "4" {
+		t.Errorf("first group = key %s, count %d, top %s, %d related", first.Key, first.Count, first.TopHit.Id, len(first.Related))
+	}
+	if groups[1].Key != "5" || groups[1].Count != 1 || len(groups[1].Related) != 0 {
+		t.Errorf("second group = %+v, want document 5 on its own", groups[1])
+	}
+}
+
+func TestSetGroupBy(t *testing.T) {
+	params := NewSearchParams("", "", "", "", "", "", "")
+	if err := params.SetGroupBy("company"); err == nil {
+		t.Errorf("SetGroupBy(company) returned no error")
+	}
+	if err := params.SetGroupBy("parent"); err != nil || params.groupBy != "parent" {
+		t.Errorf("SetGroupBy(parent) = %v", err)
+	}
+}
+
+func TestGroupHitIDsCapped(t *testing.T) {
+	newFakeElastic(t, "testdata/company_contracts.json")
+	client, err := appcontext.ElasticInstance.GetV5()
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := func() *elastic.SearchService {
+		return client.Search().Index("contracts").Type("master").Query(elastic.NewMatchAllQuery())
+	}
+
+	groups, total, capped, err := groupHitIDs(search(), "parent")
+	if err != nil || len(groups) != 5 || total != 5 || capped {
+		t.Errorf("groupHitIDs() = %d groups, total %d, capped %v, err %v; want 5 groups uncapped", len(groups), total, capped, err)
+	}
+
+	saved := maxGroupedHits
+	t.Cleanup(func() { maxGroupedHits = saved })
+	maxGroupedHits = 3
+	groups, total, capped, err = groupHitIDs(search(), "parent")
+	if err != nil || len(groups) != 3 || total != 5 || !capped {
+		t.Errorf("groupHitIDs() past the cap = %d groups, total %d, capped %v, err %v; want 3 groups capped", len(groups), total, capped, err)
+	}
+}
@@ -0,0 +1,170 @@
+package queries
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	appcontext "iltodgeree/api/internal/app_context"
+	"iltodgeree/api/internal/sql"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// mapTopResources is the number of resources listed per unit.
+var mapTopResources = 5
+
+// ResourceCount is a resource with the number of contracts of a unit that
+// concern it.
+type ResourceCount struct {
+	Key   string `json:"key"`
+	Label *Label `json:"label,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// UnitProperties are the properties of a province or district feature.
+type UnitProperties struct {
+	ID           int             `json:"id"`
+	Name         string          `json:"name"`
+	Count        int64           `json:"count"`
+	TopResources []ResourceCount `json:"top_resources"`
+}
+
+// Feature is a GeoJSON feature of a province or district.
+type Feature struct {
+	Type       string           `json:"type"`
+	ID         int              `json:"id"`
+	Geometry   *json.RawMessage `json:"geometry"`
+	Properties UnitProperties   `json:"properties"`
+}
+
+// FeatureCollection is a GeoJSON feature collection.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// UnitMap returns the provinces, or the districts of a province, as GeoJSON
+// with the number of contracts matching the search parameters in each and
+// their most frequent resources. The geometry is the unit's Location, which
+// holds a GeoJSON geometry.
+//
+// Parameters:
+//   - params: Search parameters selecting the contracts, as for /api/search
+//   - provinceID: Empty for the provinces, else the province whose districts to return
+//
+// Returns:
+//   - *FeatureCollection: One feature per unit, including units without contracts
+//   - error: Error if the units cannot be read or the search fails
+func UnitMap(params *SearchParams, provinceID string) (*FeatureCollection, error) {
+	units, err := sql.GetProvinces(provinceID)
+	if err != nil {
+		return nil, err
+	}
+
+	field := "metadata.provinces.province.keyword"
+	if provinceID != "" {
+		field = "metadata.provinces.district.keyword"
+	}
+
+	client, err := appcontext.ElasticInstance.GetV5()
+	if err != nil {
+		panic(err)
+	}
+
+	result, err := client.Search().
+		Index(os.Getenv("ELASTICSEARCH_SECONDARY")).
+		Type(os.Getenv("ELASTICSEARCH_DOC_MASTER")).
+		Query(params.filterQuery(flattenFilters(params.facetFilters(), ""))).
+		Size(0).
+		Aggregation("units", elastic.NewTermsAggregation().
+			Field(field).
+			Size(10000).
+			SubAggregation("resources", elastic.NewTermsAggregation().
+				Field("metadata.resource.keyword").
+				Size(mapTopResources))).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search: %v", err)
+	}
+
+	return unitFeatures(units, result.Aggregations), nil
+}
+
+// geometryTypes lists the GeoJSON geometry types. GeometryCollection holds
+// geometries, the others coordinates.
+var geometryTypes = map[string]bool{
+	"Point":              true,
+	"MultiPoint":         true,
+	"LineString":         true,
+	"MultiLineString":    true,
+	"Polygon":            true,
+	"MultiPolygon":       true,
+	"GeometryCollection": true,
+}
+
+// isGeometry reports whether location is a GeoJSON geometry object, rather
+// than e.g. a bare "43.6,104.4" or a number.
+func isGeometry(location string) bool {
+	var geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Geometries  json.RawMessage `json:"geometries"`
+	}
+	if err := json.Unmarshal([]byte(location), &geometry); err != nil || !geometryTypes[geometry.Type] {
+		return false
+	}
+	if geometry.Type == "GeometryCollection" {
+		return strings.HasPrefix(string(geometry.Geometries), "[")
+	}
+	return strings.HasPrefix(string(geometry.Coordinates), "[")
+}
+
+// unitFeatures joins the units with their counts from the aggregation.
+func unitFeatures(units []sql.Province, aggs elastic.Aggregations) *FeatureCollection {
+	counts := map[string]*elastic.AggregationBucketKeyItem{}
+	if terms, found := aggs.Terms("units"); found {
+		for _, bucket := range terms.Buckets {
+			counts[fmt.Sprint(bucket.Key)] = bucket
+		}
+	}
+
+	collection := &FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	for _, unit := range units {
+		feature := Feature{
+			Type: "Feature",
+			ID:   unit.ID,
+			Properties: UnitProperties{
+				ID:           unit.ID,
+				Name:         unit.Name,
+				TopResources: []ResourceCount{},
+			},
+		}
+
+		if unit.Location != "" {
+			if isGeometry(unit.Location) {
+				geometry := json.RawMessage(unit.Location)
+				feature.Geometry = &geometry
+			} else {
+				slog.Warn("location is not a GeoJSON geometry", "unit", unit.ID)
+			}
+		}
+
+		if bucket, ok := counts[strconv.Itoa(unit.ID)]; ok {
+			feature.Properties.Count = bucket.DocCount
+			if resources, found := bucket.Aggregations.Terms("resources"); found {
+				for _, r := range resources.Buckets {
+					key, label := namedLabel("resource", fmt.Sprint(r.Key))
+					feature.Properties.TopResources = append(feature.Properties.TopResources, ResourceCount{Key: key, Label: label, Count: r.DocCount})
+				}
+			}
+		}
+
+		collection.Features = append(collection.Features, feature)
+	}
+
+	return collection
+}
@@ -0,0 +1,57 @@
+package queries
+
+import (
+	"encoding/json"
+	"iltodgeree/api/internal/sql"
+	"testing"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+func TestUnitFeatures(t *testing.T) {
+	var aggs elastic.Aggregations
+	err := json.Unmarshal([]byte(`{"units": {"buckets": [
+		{"key": "12", "doc_count": 7, "resources": {"buckets": [{"key": "27", "doc_count": 5}, {"key": "41", "doc_count": 2}]}}
+	]}}`), &aggs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	units := []sql.Province{
+		{BaseProvince: sql.BaseProvince{ID: 12, Name: "Өмнөговь", Location: `{"type": "Point", "coordinates": [104.4, 43.6]}`}},
+		{BaseProvince: sql.BaseProvince{ID: 3, Name: "Архангай", Location: "not geojson"}},
+	}
+
+	collection := unitFeatures(units, aggs)
+	body, _ := json.Marshal(collection)
+
+	want := `{"type":"FeatureCollection","features":[` +
+		`{"type":"Feature","id":12,"geometry":{"type":"Point","coordinates":[104.4,43.6]},"properties":{"id":12,"name":"Өмнөговь","count":7,"top_resources":[` +
+		`{"key":"27","label":{"mn":"Нүүрс","en":"Coal"},"count":5},{"key":"41","label":{"mn":"Алт","en":"Gold"},"count":2}]}},` +
+		`{"type":"Feature","id":3,"geometry":null,"properties":{"id":3,"name":"Архангай","count":0,"top_resources":[]}}]}`
+	if string(body) != want {
+		t.Errorf("unitFeatures() = %s\nwant %s", body, want)
+	}
+}
+
+func TestIsGeometry(t *testing.T) {
+	tests := []struct {
+		location string
+		want     bool
+	}{
+		{`{"type": "Point", "coordinates": [104.4, 43.6]}`, true},
+		{`{"type": "MultiPolygon", "coordinates": [[[[100, 40], [101, 40], [101, 41], [100, 40]]]]}`, true},
+		{`{"type": "GeometryCollection", "geometries": []}`, true},
+		{`"43.6,104.4"`, false},
+		{`12`, false},
+		{`{"type": "Feature", "geometry": null}`, false},
+		{`{"type": "Point"}`, false},
+		{`{"type": "Point", "coordinates": "104.4,43.6"}`, false},
+		{`not geojson`, false},
+	}
+	for _, tt := range tests {
+		if got := isGeometry(tt.location); got != tt.want {
+			t.Errorf("isGeometry(%s) = %v, want %v", tt.location, got, tt.want)
+		}
+	}
+}
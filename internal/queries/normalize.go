@@ -0,0 +1,303 @@
+package queries
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// keyboardLayout maps the keys of a QWERTY keyboard to the letters of the
+// Mongolian Cyrillic layout at the same position.
+var keyboardLayout = map[rune]rune{
+	'q': 'ф', 'w': 'ц', 'e': 'у', 'r': 'ж', 't': 'э', 'y': 'н', 'u': 'г',
+	'i': 'ш', 'o': 'ү', 'p': 'з', '[': 'к', ']': 'ъ',
+	'a': 'й', 's': 'ы', 'd': 'б', 'f': 'ө', 'g': 'а', 'h': 'х', 'j': 'р',
+	'k': 'о', 'l': 'л', ';': 'д', '\'': 'п',
+	'z': 'я', 'x': 'ч', 'c': 'ё', 'v': 'с', 'b': 'м', 'n': 'и', 'm': 'т',
+	',': 'ь', '.': 'в', '/': 'ю', '-': 'е', '=': 'щ',
+}
+
+// keyboardLayoutReverse maps Mongolian Cyrillic letters back to their key.
+var keyboardLayoutReverse = reverseLayout(keyboardLayout)
+
+// vowelHarmonies are the readings of the ambiguous Latin vowels. Mongolian
+// words use either back (о, у) or front (ө, ү) vowels, and ө is often
+// written as "u" as well as "o".
+var vowelHarmonies = []map[rune]rune{
+	{'o': 'о', 'u': 'у'},
+	{'o': 'ө', 'u': 'ү'},
+	{'o': 'ө', 'u': 'ө'},
+}
+
+// queryOperators are the characters with a meaning in simple_query_string
+// syntax. Keyboard swaps can produce them, e.g. е is typed with the "-" key.
+const queryOperators = `-+|"*()~`
+
+// maxWordVariants caps the alternatives added per word.
+var maxWordVariants = 6
+
+// variant is an alternative spelling of a word. Transliterations are
+// approximate and are matched with fuzziness, keyboard swaps are exact.
+type variant struct {
+	text  string
+	fuzzy bool
+}
+
+func reverseLayout(layout map[rune]rune) map[rune]rune {
+	reverse := map[rune]rune{}
+	for k, v := range layout {
+		reverse[v] = k
+	}
+	return reverse
+}
+
+// isVowel reports whether r is a Latin vowel.
+func isVowel(r rune) bool {
+	return strings.ContainsRune("aeiouy", r)
+}
+
+// toCyrillic transliterates a Latin word into its likely Cyrillic spellings,
+// one per vowel harmony.
+func toCyrillic(word string) []string {
+	word = strings.ToLower(word)
+	var variants []string
+
+	for _, harmony := range vowelHarmonies {
+		var b strings.Builder
+		runes := []rune(word)
+
+		for i := 0; i < len(runes); {
+			rest := string(runes[i:])
+			matched := false
+			for _, t := range latinToCyrillic {
+				if len(t.latin) == 2 && strings.HasPrefix(rest, t.latin) {
+					b.WriteString(string([]rune(t.cyrillic)[0]))
+					i += 2
+					matched = true
+					// The long vowel ю is spelled юу or юү ("Oyu" is Оюу).
+					if t.latin == "yu" && (i == len(runes) || runes[i] != 'u') {
+						if harmony['u'] == 'у' {
+							b.WriteRune('у')
+						} else {
+							b.WriteRune('ү')
+						}
+					}
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+
+			r := runes[i]
+			switch {
+			case harmony[r] != 0:
+				b.WriteRune(harmony[r])
+			case r == 'i' && i > 0 && isVowel(runes[i-1]):
+				b.WriteRune('й')
+			case r == 'i' && i > 0 && i == len(runes)-1:
+				b.WriteRune('ь')
+			default:
+				written := false
+				for _, t := range latinToCyrillic {
+					if len(t.latin) == 1 && rune(t.latin[0]) == r {
+						b.WriteString(string([]rune(t.cyrillic)[0]))
+						written = true
+						break
+					}
+				}
+				if !written {
+					b.WriteRune(r)
+				}
+			}
+			i++
+		}
+
+		variants = appendUnique(variants, b.String())
+	}
+
+	return variants
+}
+
+// keyboardSwap retypes s as if the other keyboard layout had been active.
+func keyboardSwap(s string) string {
+	layout := keyboardLayout
+	if isCyrillic(s) {
+		layout = keyboardLayoutReverse
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if swapped, ok := layout[r]; ok {
+			b.WriteRune(swapped)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// wordVariants returns the alternative spellings of a single word: its
+// transliteration into the other script and its keyboard-layout swap.
+// Variants containing query operators are dropped.
+func wordVariants(word string) []variant {
+	lower := strings.ToLower(word)
+	var variants []variant
+	seen := map[string]bool{lower: true}
+
+	add := func(text string, fuzzy bool) {
+		if text == "" || seen[text] || len(variants) >= maxWordVariants {
+			return
+		}
+		// A variant with an operator would change the meaning of the query.
+		if strings.ContainsAny(text, queryOperators) {
+			return
+		}
+		seen[text] = true
+		variants = append(variants, variant{text: text, fuzzy: fuzzy})
+	}
+
+	if isCyrillic(word) {
+		add(toLatin(word), true)
+	} else if strings.IndexFunc(word, unicode.IsLetter) >= 0 {
+		for _, c := range toCyrillic(word) {
+			add(c, true)
+		}
+	}
+	add(keyboardSwap(word), false)
+
+	return variants
+}
+
+// fuzziness is the edit distance allowed for a transliterated word.
+func fuzziness(word string) int {
+	switch n := len([]rune(word)); {
+	case n >= 7:
+		return 2
+	case n >= 3:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// expandQuery rewrites a simple_query_string query so that every plain
+// word also matches its transliterations and keyboard-layout swap, e.g.
+// `nuurs -gold` becomes `(nuurs|нуурс~1|нүүрс~1|...) -(gold|...)`. Words in
+// quoted phrases and words using the prefix or fuzzy operators are kept.
+func expandQuery(q string) string {
+	runes := []rune(q)
+	tokens := queryTokens(q)
+
+	quoted := quotedRunes(runes)
+
+	var b strings.Builder
+	last := 0
+
+	for _, t := range tokens {
+		end := t.offset + t.length
+		if quoted[t.offset] || (end < len(runes) && (runes[end] == '*' || runes[end] == '~')) {
+			continue
+		}
+		if strings.IndexFunc(t.text, unicode.IsDigit) >= 0 {
+			continue
+		}
+
+		variants := wordVariants(t.text)
+		if len(variants) == 0 {
+			continue
+		}
+
+		alternatives := []string{t.text}
+		for _, v := range variants {
+			alternatives = append(alternatives, v.query())
+		}
+
+		b.WriteString(string(runes[last:t.offset]))
+		b.WriteString("(" + strings.Join(alternatives, "|") + ")")
+		last = end
+	}
+
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
+
+// quotedRunes reports for every rune of a query whether it lies inside a
+// quoted phrase.
+func quotedRunes(runes []rune) []bool {
+	quoted := make([]bool, len(runes))
+	inQuote := false
+	for i, r := range runes {
+		if r == '"' {
+			inQuote = !inQuote
+		}
+		quoted[i] = inQuote
+	}
+	return quoted
+}
+
+// query renders the variant in simple_query_string syntax.
+func (v variant) query() string {
+	if f := fuzziness(v.text); v.fuzzy && f > 0 {
+		return v.text + "~" + strconv.Itoa(f)
+	}
+	return v.text
+}
+
+// filterVariants returns the alternative spellings of a whole filter value,
+// such as a company name.
+func filterVariants(value string) []variant {
+	var variants []variant
+	seen := map[string]bool{strings.ToLower(value): true}
+
+	add := func(text string, fuzzy bool) {
+		if text != "" && !seen[text] {
+			seen[text] = true
+			variants = append(variants, variant{text: text, fuzzy: fuzzy})
+		}
+	}
+
+	if isCyrillic(value) {
+		add(toLatin(value), true)
+	} else {
+		var spellings [][]string
+		for _, word := range strings.Fields(value) {
+			spellings = append(spellings, toCyrillic(word))
+		}
+		for i := range vowelHarmonies {
+			var words []string
+			for _, options := range spellings {
+				words = append(words, options[min(i, len(options)-1)])
+			}
+			add(strings.Join(words, " "), true)
+		}
+	}
+	add(keyboardSwap(value), false)
+
+	return variants
+}
+
+// expandedFilter matches the exact keyword value or any of its spellings in
+// the analyzed text field.
+func expandedFilter(keywordField string, textField string, value string) elastic.Query {
+	should := []elastic.Query{elastic.NewTermQuery(keywordField, value)}
+	for _, v := range filterVariants(value) {
+		match := elastic.NewMatchQuery(textField, v.text).Operator("and")
+		if v.fuzzy {
+			match = match.Fuzziness("AUTO")
+		}
+		should = append(should, match)
+	}
+	return elastic.NewBoolQuery().Should(should...).MinimumNumberShouldMatch(1)
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
@@ -0,0 +1,125 @@
+package queries
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+func TestWordVariantsReachRealNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		typed string
+		want  string
+	}{
+		{name: "coal", typed: "nuurs", want: "нүүрс"},
+		{name: "Oyu Tolgoi, first word", typed: "Oyu", want: "оюу"},
+		{name: "Oyu Tolgoi, second word", typed: "Tolgoi", want: "толгой"},
+		{name: "Tavan Tolgoi", typed: "Tavan", want: "таван"},
+		{name: "Erdenes", typed: "Erdenes", want: "эрдэнэс"},
+		{name: "Erdenet", typed: "Erdenet", want: "эрдэнэт"},
+		{name: "Ömnögovi written with u", typed: "Umnugovi", want: "өмнөговь"},
+		{name: "Ömnögovi written with o", typed: "Omnogovi", want: "өмнөговь"},
+		{name: "Dornogovi", typed: "Dornogovi", want: "дорноговь"},
+		{name: "Baganuur", typed: "Baganuur", want: "багануур"},
+		{name: "Shivee Ovoo, first word", typed: "Shivee", want: "шивээ"},
+		{name: "Tsagaan Suvarga", typed: "Tsagaan", want: "цагаан"},
+		{name: "Selenge", typed: "Selenge", want: "сэлэнгэ"},
+		{name: "Khentii", typed: "Khentii", want: "хэнтий"},
+		{name: "Bayankhongor", typed: "Bayankhongor", want: "баянхонгор"},
+		{name: "gold", typed: "alt", want: "алт"},
+		{name: "Cyrillic to Latin", typed: "Өмнөговь", want: "omnogovi"},
+		{name: "Cyrillic to Latin, Oyu", typed: "Оюу", want: "oyu"},
+		{name: "Cyrillic typed on QWERTY", typed: "glm", want: "алт"},
+		{name: "Latin typed on Cyrillic layout", typed: "үнг", want: "oyu"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variants := wordVariants(tt.typed)
+			for _, v := range variants {
+				distance := levenshtein(v.text, tt.want)
+				if distance == 0 || (v.fuzzy && distance <= fuzziness(v.text)) {
+					return
+				}
+			}
+			t.Errorf("wordVariants(%q) = %v, none reaches %q", tt.typed, variants, tt.want)
+		})
+	}
+}
+
+func TestExpandQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		q        string
+		contains []string
+		keeps    []string
+	}{
+		{
+			name:     "words are grouped",
+			q:        "nuurs Tolgoi",
+			contains: []string{"(nuurs|", "|нүүрс~1", "(Tolgoi|", "толгой~1"},
+		},
+		{
+			name:     "negation stays outside the group",
+			q:        "alt -nuurs",
+			contains: []string{"(alt|алт~1", " -(nuurs|"},
+		},
+		{
+			name:  "phrases and operators are kept",
+			q:     `"Oyu Tolgoi" tolg* nuurs~2 2015`,
+			keeps: []string{`"Oyu Tolgoi"`, "tolg*", "nuurs~2", "2015"},
+		},
+		{
+			name:     "swaps typing operators are dropped",
+			q:        "ерөнхий",
+			contains: []string{"(ерөнхий|yeronkhii~2)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := expandQuery(tt.q)
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("expandQuery(%q) = %q, want it to contain %q", tt.q, got, want)
+				}
+			}
+			if len(tt.keeps) > 0 && got != tt.q {
+				t.Errorf("expandQuery(%q) = %q, want it unchanged", tt.q, got)
+			}
+		})
+	}
+}
+
+func TestFilterVariants(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "Latin company", value: "Erdenes Tavan Tolgoi", want: "эрдэнэс таван толгой"},
+		{name: "Cyrillic company", value: "Эрдэнэс Таван Толгой", want: "erdenes tavan tolgoi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, v := range filterVariants(tt.value) {
+				if v.text == tt.want {
+					return
+				}
+			}
+			t.Errorf("filterVariants(%q) = %v, want %q among them", tt.value, filterVariants(tt.value), tt.want)
+		})
+	}
+}
+
+func TestTransliterateToggle(t *testing.T) {
+	params := NewSearchParams("", "", "", "", "Erdenes", "", "")
+	if _, ok := params.facetFilters()["company"][0].(*elastic.BoolQuery); !ok {
+		t.Errorf("company filter is not expanded by default")
+	}
+
+	params.SetTransliterate(false)
+	if _, ok := params.facetFilters()["company"][0].(*elastic.BoolQuery); ok {
+		t.Errorf("company filter is expanded with transliterate=false")
+	}
+}
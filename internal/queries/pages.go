@@ -0,0 +1,182 @@
+package queries
+
+import (
+	"iltodgeree/api/internal/sql"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// snippetContext is the number of characters shown on each side of a match.
+var snippetContext = 60
+
+// TextMatch is an occurrence of the query in a page. Offset and Length are
+// in characters of the page text.
+type TextMatch struct {
+	Offset  int    `json:"offset"`
+	Length  int    `json:"length"`
+	Snippet string `json:"snippet"`
+}
+
+// PageMatches lists the matches on one page.
+type PageMatches struct {
+	PageNo  int         `json:"page_no"`
+	Matches []TextMatch `json:"hits"`
+}
+
+// ContractTextResult is the result of a search inside one contract.
+type ContractTextResult struct {
+	Total int           `json:"total"`
+	Pages []PageMatches `json:"pages"`
+}
+
+// SearchContractPages finds q in the pages of a contract. Words match at
+// the start of a word so that suffixed forms ("гэрээ" in "гэрээний") are
+// found, and also match their synonyms and transliterations. Quoted phrases
+// and text: clauses match as typed, negated words are ignored.
+//
+// Parameters:
+//   - pages: Pages of the contract, from sql.GetContractPages
+//   - q: Search query in the /api/search syntax
+//
+// Returns:
+//   - *ContractTextResult: Matches grouped by page, pages without matches left out
+//   - error: ErrQuerySyntax if q is malformed
+func SearchContractPages(pages []sql.ContractPage, q string) (*ContractTextResult, error) {
+	terms, err := pageSearchTerms(q)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ContractTextResult{Pages: []PageMatches{}}
+	for _, page := range pages {
+		matches := findMatches([]rune(page.Text), terms)
+		if len(matches) == 0 {
+			continue
+		}
+		result.Total += len(matches)
+		result.Pages = append(result.Pages, PageMatches{PageNo: page.PageNo, Matches: matches})
+	}
+
+	return result, nil
+}
+
+// pageSearchTerms lists the lower-cased strings to look for.
+func pageSearchTerms(q string) ([][]rune, error) {
+	clauses, rest, err := parseFieldQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var terms []string
+	for _, c := range clauses {
+		if c.prefix == "text" && !c.negated {
+			terms = appendUnique(terms, strings.ToLower(c.value))
+		}
+	}
+
+	runes := []rune(rest)
+	quoted := quotedRunes(runes)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '"' || !quoted[i] {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && runes[end] != '"' {
+			end++
+		}
+		if phrase := strings.TrimSpace(string(runes[i+1 : end])); phrase != "" {
+			terms = appendUnique(terms, strings.ToLower(phrase))
+		}
+		i = end
+	}
+
+	for _, t := range queryTokens(rest) {
+		if quoted[t.offset] || (t.offset > 0 && runes[t.offset-1] == '-') {
+			continue
+		}
+		word := strings.ToLower(t.text)
+		terms = appendUnique(terms, word)
+		for _, other := range synonyms[word] {
+			terms = appendUnique(terms, other)
+		}
+		for _, v := range wordVariants(t.text) {
+			terms = appendUnique(terms, v.text)
+		}
+	}
+
+	result := make([][]rune, 0, len(terms))
+	for _, term := range terms {
+		result = append(result, []rune(term))
+	}
+	return result, nil
+}
+
+// findMatches returns the non-overlapping matches of the terms in text. A
+// match starts at a word boundary and runs to the end of the word.
+func findMatches(text []rune, terms [][]rune) []TextMatch {
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	type span struct{ start, end int }
+	var spans []span
+	for _, term := range terms {
+		for i := 0; i+len(term) <= len(lower); i++ {
+			if i > 0 && isWordRune(lower[i-1]) {
+				continue
+			}
+			if string(lower[i:i+len(term)]) != string(term) {
+				continue
+			}
+			end := i + len(term)
+			for end < len(lower) && isWordRune(lower[end]) {
+				end++
+			}
+			spans = append(spans, span{i, end})
+		}
+	}
+
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+
+	matches := []TextMatch{}
+	last := -1
+	for _, s := range spans {
+		if s.start < last {
+			continue
+		}
+		matches = append(matches, TextMatch{Offset: s.start, Length: s.end - s.start, Snippet: snippet(text, s.start, s.end)})
+		last = s.end
+	}
+	return matches
+}
+
+// snippet returns the text around a match with the match highlighted the
+// same way as /api/search highlights.
+func snippet(text []rune, start int, end int) string {
+	from := max(0, start-snippetContext)
+	to := min(len(text), end+snippetContext)
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	b.WriteString(string(text[from:start]))
+	b.WriteString("<strong>" + string(text[start:end]) + "</strong>")
+	b.WriteString(string(text[end:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,77 @@
+package queries
+
+import (
+	"iltodgeree/api/internal/sql"
+	"testing"
+)
+
+func TestSearchContractPages(t *testing.T) {
+	pages := []sql.ContractPage{
+		{PageNo: 1, Text: "Энэхүү гэрээний зорилго нь алт олборлох явдал юм."},
+		{PageNo: 2, Text: "The royalty rate shall be 5%.\nRoyalty payments are due monthly."},
+		{PageNo: 3, Text: "Нүүрс болон зэс."},
+	}
+
+	tests := []struct {
+		name      string
+		q         string
+		wantPages []int
+		wantFirst TextMatch
+	}{
+		{
+			name:      "suffixed word",
+			q:         "гэрээ",
+			wantPages: []int{1},
+			wantFirst: TextMatch{Offset: 7, Length: 8, Snippet: "Энэхүү <strong>гэрээний</strong> зорилго нь алт олборлох явдал юм."},
+		},
+		{
+			name:      "case-insensitive repeats",
+			q:         "royalty",
+			wantPages: []int{2, 2},
+			wantFirst: TextMatch{Offset: 4, Length: 7, Snippet: "The <strong>royalty</strong> rate shall be 5%. Royalty payments are due monthly."},
+		},
+		{name: "phrase", q: `"rate shall"`, wantPages: []int{2}, wantFirst: TextMatch{Offset: 12, Length: 10}},
+		{name: "text prefix", q: `text:"payments are"`, wantPages: []int{2}, wantFirst: TextMatch{Offset: 38, Length: 12}},
+		{name: "English synonym", q: "gold", wantPages: []int{1}, wantFirst: TextMatch{Offset: 27, Length: 3}},
+		{name: "negated word is ignored", q: "-coal", wantPages: nil},
+		{name: "word inside another word", q: "ялт", wantPages: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := SearchContractPages(pages, tt.q)
+			if err != nil {
+				t.Fatalf("SearchContractPages() error = %v", err)
+			}
+
+			var gotPages []int
+			for _, p := range res.Pages {
+				for range p.Matches {
+					gotPages = append(gotPages, p.PageNo)
+				}
+			}
+			if len(gotPages) != len(tt.wantPages) || res.Total != len(tt.wantPages) {
+				t.Fatalf("SearchContractPages(%q) pages = %v (total %d), want %v", tt.q, gotPages, res.Total, tt.wantPages)
+			}
+			for i := range gotPages {
+				if gotPages[i] != tt.wantPages[i] {
+					t.Fatalf("SearchContractPages(%q) pages = %v, want %v", tt.q, gotPages, tt.wantPages)
+				}
+			}
+			if len(gotPages) == 0 {
+				return
+			}
+
+			first := res.Pages[0].Matches[0]
+			if first.Offset != tt.wantFirst.Offset || first.Length != tt.wantFirst.Length {
+				t.Errorf("first match = %d+%d, want %d+%d", first.Offset, first.Length, tt.wantFirst.Offset, tt.wantFirst.Length)
+			}
+			if tt.wantFirst.Snippet != "" && first.Snippet != tt.wantFirst.Snippet {
+				t.Errorf("snippet = %q, want %q", first.Snippet, tt.wantFirst.Snippet)
+			}
+		})
+	}
+
+	if _, err := SearchContractPages(pages, "company:"); err == nil {
+		t.Errorf("SearchContractPages() with a prefix without a value returned no error")
+	}
+}
@@ -0,0 +1,225 @@
+package queries
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SearchParamsFromValues builds SearchParams from the query string accepted
+// by /api/search. It is shared by every endpoint that takes search filters
+// and by saved searches, which store the query string as-is.
+//
+// Parameters:
+//   - values: Parsed URL query values
+//
+// Returns:
+//   - *SearchParams: Configured search parameters object
+//   - error: Error if a parameter has an invalid value
+func SearchParamsFromValues(values url.Values) (*SearchParams, error) {
+	params := NewSearchParams(
+		values.Get("q"),
+		values.Get("year"),
+		values.Get("contract_type"),
+		values.Get("resource"),
+		values.Get("company"),
+		values.Get("government"),
+		values.Get("document_type"),
+	)
+
+	if err := params.ParseFieldQuery(); err != nil {
+		return nil, err
+	}
+
+	if values.Get("province") != "" {
+		params.SetProvince(values.Get("province"))
+	}
+
+	if values.Get("district") != "" {
+		params.SetDistrict(values.Get("district"))
+	}
+
+	if values.Get("annotation_category") != "" {
+		params.SetAnnotationCategories(values.Get("annotation_category"))
+	}
+
+	if err := params.SetAnnotationMatch(values.Get("annotation_category_match")); err != nil {
+		return nil, err
+	}
+
+	if values.Get("annotated") != "" {
+		ann, err := strconv.ParseBool(values.Get("annotated"))
+		if err != nil {
+			return nil, fmt.Errorf("annotated: boolean утгыг хөрвүүлж чадсангүй")
+		}
+		params.SetAnnotated(ann)
+	}
+
+	if values.Get("facets") != "" {
+		facets, err := strconv.ParseBool(values.Get("facets"))
+		if err != nil {
+			return nil, fmt.Errorf("facets: boolean утгыг хөрвүүлж чадсангүй")
+		}
+		params.SetFacets(facets)
+	}
+
+	if values.Get("auto_correct") != "" {
+		autoCorrect, err := strconv.ParseBool(values.Get("auto_correct"))
+		if err != nil {
+			return nil, fmt.Errorf("auto_correct: boolean утгыг хөрвүүлж чадсангүй")
+		}
+		params.SetAutoCorrect(autoCorrect)
+	}
+
+	if values.Get("transliterate") != "" {
+		transliterate, err := strconv.ParseBool(values.Get("transliterate"))
+		if err != nil {
+			return nil, fmt.Errorf("transliterate: boolean утгыг хөрвүүлж чадсангүй")
+		}
+		params.SetTransliterate(transliterate)
+	}
+
+	for _, d := range dimensions {
+		params.SetExclude(d.name, values.Get("exclude_"+d.name))
+	}
+
+	signedFrom, signedTo, err := dateRangeValues(values, "signed_from", "signed_to")
+	if err != nil {
+		return nil, err
+	}
+	params.SetSignedRange(signedFrom, signedTo)
+
+	createdFrom, createdTo, err := dateRangeValues(values, "created_from", "created_to")
+	if err != nil {
+		return nil, err
+	}
+	params.SetCreatedRange(createdFrom, createdTo)
+
+	params.SetSize(values.Get("size"))
+	params.SetFrom(values.Get("from"))
+
+	if err := params.SetProfile(values.Get("profile")); err != nil {
+		return nil, err
+	}
+
+	params.SetSortBy(values.Get("sort_by"))
+	params.SetOrder(values.Get("is_asc"))
+
+	if err := params.SetGroupBy(values.Get("group_by")); err != nil {
+		return nil, err
+	}
+
+	if values.Get("cursor") != "" {
+		if params.groupBy != "" {
+			return nil, fmt.Errorf("cursor cannot be combined with group_by")
+		}
+		if err := params.SetCursor(values.Get("cursor")); err != nil {
+			return nil, err
+		}
+	}
+
+	return params, nil
+}
+
+// dateRangeValues parses an optional pair of ISO dates (YYYY-MM-DD) and
+// checks that the range is not reversed.
+func dateRangeValues(values url.Values, fromKey string, toKey string) (*time.Time, *time.Time, error) {
+	var from, to *time.Time
+
+	for _, bound := range []struct {
+		key  string
+		dest **time.Time
+	}{{fromKey, &from}, {toKey, &to}} {
+		value := values.Get(bound.key)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: огноо YYYY-MM-DD хэлбэртэй байх ёстой", bound.key)
+		}
+		*bound.dest = &t
+	}
+
+	if from != nil && to != nil && to.Before(*from) {
+		return nil, nil, fmt.Errorf("%s нь %s-с өмнө байж болохгүй", toKey, fromKey)
+	}
+
+	return from, to, nil
+}
+
+// Normalized returns the search in a canonical form for analytics: the
+// lower-cased q with single spaces, and every filter that is set with its
+// values as sorted strings. Paging, sorting and display options are left
+// out, so the same search always normalizes the same way.
+//
+// Returns:
+//   - string: The normalized q
+//   - map[string][]string: Filter values keyed by parameter name
+func (s *SearchParams) Normalized() (string, map[string][]string) {
+	q := strings.Join(strings.Fields(strings.ToLower(s.q)), " ")
+	filters := map[string][]string{}
+
+	add := func(name string, values ...interface{}) {
+		for _, v := range values {
+			if value := fmt.Sprint(v); value != "" {
+				filters[name] = append(filters[name], value)
+			}
+		}
+		sort.Strings(filters[name])
+		if len(filters[name]) == 0 {
+			delete(filters, name)
+		}
+	}
+
+	add("year", s.years...)
+	add("resource", s.resources...)
+	add("contract_type", s.contractTypes...)
+	add("document_type", s.documentTypes...)
+	add("province", s.province)
+	add("district", s.district...)
+	add("company", s.companies...)
+	add("government", s.governments...)
+	add("annotation_category", s.annotationCategory...)
+	if s.annotated != nil {
+		add("annotated", *s.annotated)
+	}
+	if len(s.annotationCategory) > 1 {
+		add("annotation_category_match", s.annotationMatch)
+	}
+
+	for name, values := range s.excludes {
+		add("exclude_"+name, values...)
+	}
+
+	for name, t := range map[string]*time.Time{"signed_from": s.signedFrom, "signed_to": s.signedTo, "created_from": s.createdFrom, "created_to": s.createdTo} {
+		if t != nil {
+			add(name, t.Format(dateLayout))
+		}
+	}
+
+	for _, c := range s.fieldClauses {
+		clause := c.prefix + ":" + strings.ToLower(c.value)
+		if c.negated {
+			clause = "-" + clause
+		}
+		add("fields", clause)
+	}
+
+	add("group_by", s.groupBy)
+	add("profile", s.profile)
+
+	return q, filters
+}
+
+// Offset returns the offset of the first hit of the page, or -1 when the
+// page follows a cursor and its offset is not known.
+func (s *SearchParams) Offset() int {
+	if s.cursor != nil {
+		return -1
+	}
+	return s.from
+}
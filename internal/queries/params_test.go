@@ -0,0 +1,124 @@
+package queries
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSearchParamsDateRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantErr  bool
+		contains []string
+	}{
+		{
+			name:     "signed range with years",
+			query:    "signed_from=2012-03-01&signed_to=2015-06-30&year=2014",
+			contains: []string{`"metadata.signature_date":{"from":"2012-03-01","include_lower":true,"include_upper":true,"to":"2015-06-30||/d"}`, `"metadata.signature_year":[2014]`},
+		},
+		{
+			name:     "open-ended signed range",
+			query:    "signed_from=2012-03-01",
+			contains: []string{`"from":"2012-03-01"`, `"to":null`},
+		},
+		{
+			name:     "created range",
+			query:    "created_to=2020-01-31",
+			contains: []string{`"created_at":{"from":null`, `"to":"2020-01-31||/d"`},
+		},
+		{name: "invalid date", query: "signed_from=2012-13-01", wantErr: true},
+		{name: "not ISO", query: "created_from=01/03/2012", wantErr: true},
+		{name: "reversed range", query: "signed_from=2015-01-01&signed_to=2014-01-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			params, err := SearchParamsFromValues(values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SearchParamsFromValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var body []string
+			for _, f := range flattenFilters(params.facetFilters(), "") {
+				src, _ := f.Source()
+				b, _ := json.Marshal(src)
+				body = append(body, string(b))
+			}
+			joined := strings.Join(body, "\n")
+			for _, want := range tt.contains {
+				if !strings.Contains(joined, want) {
+					t.Errorf("filters = %s, want them to contain %s", joined, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchParamsExcludes(t *testing.T) {
+	values, _ := url.ParseQuery("resource=27&exclude_contract_type=Концессийн гэрээ,Бусад&exclude_province=12&exclude_company=Erdenes Tavan Tolgoi&exclude_district=1,x")
+	params, err := SearchParamsFromValues(values)
+	if err != nil {
+		t.Fatalf("SearchParamsFromValues() error = %v", err)
+	}
+	params.SetTransliterate(false)
+
+	excludes := params.excludeFilters()
+	if len(excludes) != 4 {
+		t.Fatalf("excludeFilters() returned %d clauses, want 4", len(excludes))
+	}
+
+	var body []string
+	for _, f := range excludes {
+		src, _ := f.Source()
+		b, _ := json.Marshal(src)
+		body = append(body, string(b))
+	}
+	want := []string{
+		`{"terms":{"metadata.contract_type.keyword":["Concession Agreement","Other"]}}`,
+		`{"terms":{"metadata.provinces.province":["12"]}}`,
+		`{"terms":{"metadata.provinces.district":[1]}}`,
+		`{"terms":{"metadata.company_name.keyword":["Erdenes Tavan Tolgoi"]}}`,
+	}
+	for i := range want {
+		if body[i] != want[i] {
+			t.Errorf("excludeFilters()[%d] = %s, want %s", i, body[i], want[i])
+		}
+	}
+
+	if grouped := params.facetFilters(); len(grouped["contract_type"]) != 0 || len(grouped["resource"]) != 1 {
+		t.Errorf("excludes leaked into the include filters: %v", grouped)
+	}
+}
+
+func TestSearchParamsNormalized(t *testing.T) {
+	a, _ := url.ParseQuery("q=  Gold   Mine company:Oyu &resource=41,27&exclude_province=12&signed_from=2012-03-01&size=50&sort_by=year")
+	b, _ := url.ParseQuery("q=gold mine COMPANY:oyu&resource=27,41&exclude_province=12&signed_from=2012-03-01&from=20")
+
+	paramsA, err := SearchParamsFromValues(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paramsB, err := SearchParamsFromValues(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qA, filtersA := paramsA.Normalized()
+	qB, filtersB := paramsB.Normalized()
+	bodyA, _ := json.Marshal(filtersA)
+	bodyB, _ := json.Marshal(filtersB)
+
+	if qA != "gold mine" || qA != qB {
+		t.Errorf("Normalized() q = %q and %q, want both \"gold mine\"", qA, qB)
+	}
+	want := `{"exclude_province":["12"],"fields":["company:oyu"],"resource":["27","41"],"signed_from":["2012-03-01"]}`
+	if string(bodyA) != want || string(bodyB) != want {
+		t.Errorf("Normalized() filters = %s and %s, want %s", bodyA, bodyB, want)
+	}
+}
@@ -0,0 +1,177 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	appcontext "iltodgeree/api/internal/app_context"
+	"os"
+	"sort"
+	"strconv"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// pivotDimensions lists the dimensions a pivot table can use as rows or
+// columns.
+var pivotDimensions = []string{"year", "resource", "contract_type", "document_type", "province", "district", "company", "government", "annotation_category"}
+
+// pivotTermsSize caps the values read per dimension.
+var pivotTermsSize = 1000
+
+// PivotHeader is a row or column of a pivot table. Total is the number of
+// contracts with the value.
+type PivotHeader struct {
+	Key   string `json:"key"`
+	Label *Label `json:"label,omitempty"`
+	Total int64  `json:"total"`
+	// bucketKey is the value as stored, which Key may translate.
+	bucketKey string
+}
+
+// PivotResult is the response of /api/stats/pivot. Cells[i][j] is the
+// number of contracts with both Rows[i] and Cols[j].
+type PivotResult struct {
+	RowDimension string        `json:"row_dimension"`
+	ColDimension string        `json:"col_dimension"`
+	Rows         []PivotHeader `json:"rows"`
+	Cols         []PivotHeader `json:"cols"`
+	Cells        [][]int64     `json:"cells"`
+	Total        int64         `json:"total"`
+}
+
+// Pivot cross-tabulates the contracts matching the search parameters by
+// two dimensions. A contract with several values of a dimension counts in
+// each of them, so the totals are contract counts and can be less than the
+// sum of their cells.
+//
+// Parameters:
+//   - params: Search parameters selecting the contracts, as for /api/search
+//   - rows: Dimension of the rows, one of pivotDimensions
+//   - cols: Dimension of the columns, one of pivotDimensions
+//
+// Returns:
+//   - *PivotResult: The matrix; years are ordered by year, other values by total
+//   - error: ErrInvalidStatsParameter for an unknown or repeated dimension, or the search error
+func Pivot(params *SearchParams, rows string, cols string) (*PivotResult, error) {
+	rowField := dimensionField(rows, pivotDimensions)
+	if rowField == "" {
+		return nil, fmt.Errorf("%w: rows must be one of %v", ErrInvalidStatsParameter, pivotDimensions)
+	}
+	colField := dimensionField(cols, pivotDimensions)
+	if colField == "" {
+		return nil, fmt.Errorf("%w: cols must be one of %v", ErrInvalidStatsParameter, pivotDimensions)
+	}
+	if rows == cols {
+		return nil, fmt.Errorf("%w: rows and cols must differ", ErrInvalidStatsParameter)
+	}
+
+	client, err := appcontext.ElasticInstance.GetV5()
+	if err != nil {
+		panic(err)
+	}
+
+	result, err := client.Search().
+		Index(os.Getenv("ELASTICSEARCH_SECONDARY")).
+		Type(os.Getenv("ELASTICSEARCH_DOC_MASTER")).
+		Query(params.filterQuery(flattenFilters(params.facetFilters(), ""))).
+		Size(0).
+		Aggregation("rows", elastic.NewTermsAggregation().
+			Field(rowField).
+			Size(pivotTermsSize).
+			SubAggregation("cols", elastic.NewTermsAggregation().Field(colField).Size(pivotTermsSize))).
+		Aggregation("cols", elastic.NewTermsAggregation().Field(colField).Size(pivotTermsSize)).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search: %v", err)
+	}
+
+	return parsePivot(result.Aggregations, rows, cols, result.Hits.TotalHits), nil
+}
+
+// parsePivot reads the matrix from the aggregation result.
+func parsePivot(aggs elastic.Aggregations, rows string, cols string, total int64) *PivotResult {
+	pivot := &PivotResult{RowDimension: rows, ColDimension: cols, Rows: []PivotHeader{}, Cols: []PivotHeader{}, Cells: [][]int64{}, Total: total}
+
+	colIndex := map[string]int{}
+	if terms, found := aggs.Terms("cols"); found {
+		pivot.Cols = pivotHeaders(terms, cols)
+		for j, col := range pivot.Cols {
+			colIndex[col.bucketKey] = j
+		}
+	}
+
+	terms, found := aggs.Terms("rows")
+	if !found {
+		return pivot
+	}
+	pivot.Rows = pivotHeaders(terms, rows)
+
+	buckets := map[string]*elastic.AggregationBucketKeyItem{}
+	for _, bucket := range terms.Buckets {
+		buckets[fmt.Sprint(bucket.Key)] = bucket
+	}
+	for _, row := range pivot.Rows {
+		cells := make([]int64, len(pivot.Cols))
+		if sub, found := buckets[row.bucketKey].Aggregations.Terms("cols"); found {
+			for _, bucket := range sub.Buckets {
+				if j, ok := colIndex[fmt.Sprint(bucket.Key)]; ok {
+					cells[j] = bucket.DocCount
+				}
+			}
+		}
+		pivot.Cells = append(pivot.Cells, cells)
+	}
+
+	return pivot
+}
+
+// pivotHeaders labels the buckets of a dimension and orders them.
+func pivotHeaders(terms *elastic.AggregationBucketKeyItems, dimension string) []PivotHeader {
+	headers := []PivotHeader{}
+	for _, bucket := range terms.Buckets {
+		key, label := namedLabel(dimension, fmt.Sprint(bucket.Key))
+		headers = append(headers, PivotHeader{Key: key, Label: label, Total: bucket.DocCount, bucketKey: fmt.Sprint(bucket.Key)})
+	}
+
+	if dimension == "year" {
+		sort.SliceStable(headers, func(i, j int) bool {
+			a, _ := strconv.Atoi(headers[i].Key)
+			b, _ := strconv.Atoi(headers[j].Key)
+			return a < b
+		})
+	}
+	return headers
+}
+
+// Table lays the pivot out for download: a header row with the column
+// labels, one row per row value and a last row of column totals, each row
+// ending with its total. Labels are in Mongolian.
+func (p *PivotResult) Table() [][]string {
+	header := []string{p.RowDimension + " / " + p.ColDimension}
+	for _, col := range p.Cols {
+		header = append(header, labelText(col.Key, col.Label))
+	}
+	table := [][]string{append(header, "Нийт")}
+
+	for i, row := range p.Rows {
+		line := []string{labelText(row.Key, row.Label)}
+		for _, count := range p.Cells[i] {
+			line = append(line, strconv.FormatInt(count, 10))
+		}
+		table = append(table, append(line, strconv.FormatInt(row.Total, 10)))
+	}
+
+	totals := []string{"Нийт"}
+	for _, col := range p.Cols {
+		totals = append(totals, strconv.FormatInt(col.Total, 10))
+	}
+	return append(table, append(totals, strconv.FormatInt(p.Total, 10)))
+}
+
+// labelText returns the Mongolian label, or the key when there is none.
+func labelText(key string, label *Label) string {
+	if label == nil {
+		return key
+	}
+	return label.Mn
+}
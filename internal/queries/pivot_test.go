@@ -0,0 +1,92 @@
+package queries
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+func testPivot(t *testing.T) *PivotResult {
+	var aggs elastic.Aggregations
+	err := json.Unmarshal([]byte(`{
+		"rows": {"buckets": [
+			{"key": "41", "doc_count": 5, "cols": {"buckets": [{"key": "2016", "doc_count": 3}, {"key": "2015", "doc_count": 2}]}},
+			{"key": "27", "doc_count": 2, "cols": {"buckets": [{"key": "2015", "doc_count": 2}]}}
+		]},
+		"cols": {"buckets": [{"key": "2015", "doc_count": 3}, {"key": "2016", "doc_count": 3}]}
+	}`), &aggs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsePivot(aggs, "resource", "year", 6)
+}
+
+func TestParsePivot(t *testing.T) {
+	pivot := testPivot(t)
+
+	if len(pivot.Rows) != 2 || pivot.Rows[0].Label.Mn != "Алт" || pivot.Rows[0].Total != 5 || pivot.Rows[1].Key != "27" {
+		t.Fatalf("rows = %+v", pivot.Rows)
+	}
+	if len(pivot.Cols) != 2 || pivot.Cols[0].Key != "2015" || pivot.Cols[1].Key != "2016" {
+		t.Fatalf("cols = %+v, want years in order", pivot.Cols)
+	}
+
+	want := [][]int64{{2, 3}, {2, 0}}
+	for i := range want {
+		for j := range want[i] {
+			if pivot.Cells[i][j] != want[i][j] {
+				t.Errorf("cells = %v, want %v", pivot.Cells, want)
+			}
+		}
+	}
+}
+
+func TestPivotTable(t *testing.T) {
+	table := testPivot(t).Table()
+
+	var lines []string
+	for _, row := range table {
+		lines = append(lines, strings.Join(row, ","))
+	}
+	want := "resource / year,2015,2016,Нийт|Алт,2,3,5|Нүүрс,2,0,2|Нийт,3,3,6"
+	if strings.Join(lines, "|") != want {
+		t.Errorf("Table() = %v, want %s", lines, want)
+	}
+}
+
+func TestParsePivotSameKey(t *testing.T) {
+	// Both contract types are shown as Концессийн гэрээ but keep their own
+	// counts.
+	var aggs elastic.Aggregations
+	err := json.Unmarshal([]byte(`{
+		"rows": {"buckets": [
+			{"key": "Concession Agreement", "doc_count": 3, "cols": {"buckets": [{"key": "2015", "doc_count": 3}]}},
+			{"key": "Концессийн гэрээ", "doc_count": 1, "cols": {"buckets": [{"key": "2016", "doc_count": 1}]}}
+		]},
+		"cols": {"buckets": [{"key": "2015", "doc_count": 3}, {"key": "2016", "doc_count": 1}]}
+	}`), &aggs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pivot := parsePivot(aggs, "contract_type", "year", 4)
+	want := [][]int64{{3, 0}, {0, 1}}
+	for i := range want {
+		for j := range want[i] {
+			if pivot.Cells[i][j] != want[i][j] {
+				t.Fatalf("cells = %v, want %v", pivot.Cells, want)
+			}
+		}
+	}
+}
+
+func TestPivotDimensions(t *testing.T) {
+	for _, dims := range [][2]string{{"resource", "pdf"}, {"text", "year"}, {"year", "year"}} {
+		if _, err := Pivot(&SearchParams{}, dims[0], dims[1]); !errors.Is(err, ErrInvalidStatsParameter) {
+			t.Errorf("Pivot(%q, %q) error = %v, want ErrInvalidStatsParameter", dims[0], dims[1], err)
+		}
+	}
+}
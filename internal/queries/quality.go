@@ -0,0 +1,245 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	appcontext "iltodgeree/api/internal/app_context"
+	"iltodgeree/api/internal/correction"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// ErrUnknownQualityCheck is returned for a check name that is not one of
+// the data-quality checks.
+var ErrUnknownQualityCheck = errors.New("unknown quality check")
+
+// qualityCheck finds the contracts with one data-quality problem. Checks
+// with a mapped field find the contracts with a value of the field missing
+// from the map; the others use query.
+type qualityCheck struct {
+	name        string
+	description string
+	query       elastic.Query
+	field       string
+	mapped      map[string]string
+}
+
+// qualityChecks lists the checks in report order.
+var qualityChecks = []qualityCheck{
+	{
+		name:        "missing_signature_date",
+		description: "No signature date",
+		query:       elastic.NewBoolQuery().MustNot(elastic.NewExistsQuery("metadata.signature_date")),
+	},
+	{
+		name:        "empty_provinces",
+		description: "No province",
+		query:       elastic.NewBoolQuery().MustNot(elastic.NewExistsQuery("metadata.provinces.province")),
+	},
+	{
+		name:        "unmapped_resource",
+		description: "Resource ID missing from the resource corrections",
+		field:       "metadata.resource.keyword",
+		mapped:      correction.Resources,
+	},
+	{
+		name:        "unmapped_contract_type",
+		description: "Contract type missing from the contract type corrections",
+		field:       "metadata.contract_type.keyword",
+		mapped:      correction.ContractTypes,
+	},
+	{
+		name:        "hidden_pdf_text",
+		description: "Text hidden with show_pdf_text=false",
+		query:       elastic.NewTermQuery("metadata.show_pdf_text", false),
+	},
+	{
+		name:        "ocr_not_reviewed",
+		description: "OCR text not reviewed, is_ocr_reviewed=false",
+		query:       elastic.NewTermQuery("metadata.is_ocr_reviewed", false),
+	},
+}
+
+// QualityCheckResult is the outcome of one check. Values lists the
+// unmapped values for the correction checks.
+type QualityCheckResult struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Count       int64    `json:"count"`
+	Values      []string `json:"values,omitempty"`
+	IDs         []string `json:"ids"`
+}
+
+// QualityReport is the response of /api/quality.
+type QualityReport struct {
+	Total  int64                `json:"total"`
+	From   int                  `json:"from"`
+	Size   int                  `json:"size"`
+	Checks []QualityCheckResult `json:"checks"`
+}
+
+// CheckQuality runs the data-quality checks over all contracts and lists
+// the IDs of the offending ones, ordered by ID.
+//
+// Parameters:
+//   - check: Empty for every check, else the name of the only check to run
+//   - from: Offset of the first ID listed per check
+//   - size: Maximum number of IDs listed per check
+//
+// Returns:
+//   - *QualityReport: The number of contracts and the result of each check
+//   - error: ErrUnknownQualityCheck for an unknown check, or the search error
+func CheckQuality(check string, from int, size int) (*QualityReport, error) {
+	checks := qualityChecks
+	if check != "" {
+		checks = nil
+		for _, c := range qualityChecks {
+			if c.name == check {
+				checks = append(checks, c)
+			}
+		}
+		if len(checks) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownQualityCheck, check)
+		}
+	}
+
+	client, err := appcontext.ElasticInstance.GetV5()
+	if err != nil {
+		panic(err)
+	}
+
+	total, err := client.Count(os.Getenv("ELASTICSEARCH_SECONDARY")).
+		Type(os.Getenv("ELASTICSEARCH_DOC_MASTER")).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error counting contracts: %v", err)
+	}
+
+	report := &QualityReport{Total: total, From: from, Size: size, Checks: []QualityCheckResult{}}
+	for _, c := range checks {
+		result := QualityCheckResult{Name: c.name, Description: c.description, IDs: []string{}}
+
+		query := c.query
+		if c.mapped != nil {
+			result.Values, err = unmappedValues(client, c.field, c.mapped)
+			if err != nil {
+				return nil, err
+			}
+			if len(result.Values) == 0 {
+				report.Checks = append(report.Checks, result)
+				continue
+			}
+			query = elastic.NewTermsQuery(c.field, interfaces(result.Values)...)
+		}
+
+		res, err := client.Search().
+			Index(os.Getenv("ELASTICSEARCH_SECONDARY")).
+			Type(os.Getenv("ELASTICSEARCH_DOC_MASTER")).
+			Query(query).
+			FetchSource(false).
+			Sort("_uid", true).
+			From(from).
+			Size(size).
+			Do(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("error executing %s check: %v", c.name, err)
+		}
+
+		result.Count = res.Hits.TotalHits
+		for _, hit := range res.Hits.Hits {
+			result.IDs = append(result.IDs, hit.Id)
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report, nil
+}
+
+// unmappedValues returns the values of a field that are missing from a
+// correction map, in order.
+func unmappedValues(client *elastic.Client, field string, mapped map[string]string) ([]string, error) {
+	res, err := client.Search().
+		Index(os.Getenv("ELASTICSEARCH_SECONDARY")).
+		Type(os.Getenv("ELASTICSEARCH_DOC_MASTER")).
+		Size(0).
+		Aggregation("values", elastic.NewTermsAggregation().Field(field).Size(10000)).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error reading the values of %s: %v", field, err)
+	}
+
+	var keys []string
+	if terms, found := res.Aggregations.Terms("values"); found {
+		for _, bucket := range terms.Buckets {
+			keys = append(keys, fmt.Sprint(bucket.Key))
+		}
+	}
+	return unmapped(keys, mapped), nil
+}
+
+// unmapped returns the keys missing from a correction map, sorted.
+func unmapped(keys []string, mapped map[string]string) []string {
+	missing := []string{}
+	for _, key := range keys {
+		if _, ok := mapped[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// ParseQualityThresholds reads the maximum contract count of checks, given
+// as check=count pairs separated by commas, e.g.
+// "missing_signature_date=0,ocr_not_reviewed=50".
+//
+// Parameters:
+//   - spec: The thresholds; empty for none
+//
+// Returns:
+//   - map[string]int64: Maximum count by check name
+//   - error: ErrUnknownQualityCheck for an unknown check, or an error for a malformed pair
+func ParseQualityThresholds(spec string) (map[string]int64, error) {
+	thresholds := map[string]int64{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("threshold %q: expected check=count", pair)
+		}
+		known := false
+		for _, c := range qualityChecks {
+			known = known || c.name == name
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownQualityCheck, name)
+		}
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("threshold %q: count must be a non-negative integer", pair)
+		}
+		thresholds[name] = count
+	}
+	return thresholds, nil
+}
+
+// Exceeded describes the checks whose count is above their threshold.
+// Checks without a threshold never fail.
+func (r *QualityReport) Exceeded(thresholds map[string]int64) []string {
+	var exceeded []string
+	for _, c := range r.Checks {
+		if limit, ok := thresholds[c.Name]; ok && c.Count > limit {
+			exceeded = append(exceeded, fmt.Sprintf("%s: %d contracts, threshold %d", c.Name, c.Count, limit))
+		}
+	}
+	return exceeded
+}
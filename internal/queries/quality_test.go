@@ -0,0 +1,80 @@
+package queries
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckQuality(t *testing.T) {
+	newFakeElastic(t, "testdata/quality_contracts.json")
+
+	report, err := CheckQuality("", 0, 1)
+	if err != nil {
+		t.Fatalf("CheckQuality() error = %v", err)
+	}
+	if report.Total != 4 || len(report.Checks) != len(qualityChecks) {
+		t.Fatalf("CheckQuality() = %+v", report)
+	}
+
+	want := map[string]struct {
+		count int64
+		id    string
+	}{
+		"missing_signature_date": {2, "2"},
+		"empty_provinces":        {1, "2"},
+		"hidden_pdf_text":        {2, "2"},
+		"ocr_not_reviewed":       {2, "3"},
+	}
+	for _, c := range report.Checks {
+		w, ok := want[c.Name]
+		if !ok {
+			continue
+		}
+		if c.Count != w.count || strings.Join(c.IDs, ",") != w.id {
+			t.Errorf("%s = %d %v, want %d [%s]", c.Name, c.Count, c.IDs, w.count, w.id)
+		}
+	}
+
+	page, err := CheckQuality("ocr_not_reviewed", 1, 10)
+	if err != nil {
+		t.Fatalf("CheckQuality(ocr_not_reviewed) error = %v", err)
+	}
+	if len(page.Checks) != 1 || strings.Join(page.Checks[0].IDs, ",") != "4" {
+		t.Errorf("CheckQuality(ocr_not_reviewed, 1, 10) = %+v, want the second ID only", page.Checks)
+	}
+
+	if _, err := CheckQuality("typos", 0, 10); !errors.Is(err, ErrUnknownQualityCheck) {
+		t.Errorf("CheckQuality(typos) error = %v, want ErrUnknownQualityCheck", err)
+	}
+}
+
+func TestUnmapped(t *testing.T) {
+	got := unmapped([]string{"999", "41", "1000", "27"}, map[string]string{"41": "Алт", "27": "Нүүрс"})
+	if strings.Join(got, ",") != "1000,999" {
+		t.Errorf("unmapped() = %v, want [1000 999]", got)
+	}
+}
+
+func TestQualityThresholds(t *testing.T) {
+	thresholds, err := ParseQualityThresholds("missing_signature_date=0, ocr_not_reviewed=5")
+	if err != nil {
+		t.Fatalf("ParseQualityThresholds() error = %v", err)
+	}
+
+	report := &QualityReport{Checks: []QualityCheckResult{
+		{Name: "missing_signature_date", Count: 2},
+		{Name: "ocr_not_reviewed", Count: 5},
+		{Name: "hidden_pdf_text", Count: 100},
+	}}
+	exceeded := report.Exceeded(thresholds)
+	if len(exceeded) != 1 || !strings.HasPrefix(exceeded[0], "missing_signature_date: 2 contracts") {
+		t.Errorf("Exceeded() = %v, want missing_signature_date only", exceeded)
+	}
+
+	for _, spec := range []string{"typos=1", "ocr_not_reviewed", "ocr_not_reviewed=-1", "ocr_not_reviewed=many"} {
+		if _, err := ParseQualityThresholds(spec); err == nil {
+			t.Errorf("ParseQualityThresholds(%q) error = nil, want an error", spec)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package queries
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// ErrUnknownProfile is returned for a relevance profile that is not configured.
+var ErrUnknownProfile = errors.New("unknown relevance profile")
+
+//go:embed relevance.json
+var defaultRelevanceConfig []byte
+
+// RecencyBoost favours recently signed contracts with a gauss decay: a
+// contract signed within offset of today gets the full weight, one signed
+// scale earlier gets weight*decay.
+type RecencyBoost struct {
+	Field  string  `json:"field"`
+	Scale  string  `json:"scale"`
+	Offset string  `json:"offset"`
+	Decay  float64 `json:"decay"`
+	Weight float64 `json:"weight"`
+}
+
+// RelevanceProfile configures how full-text hits are scored.
+type RelevanceProfile struct {
+	Description string             `json:"description"`
+	Operator    string             `json:"operator"`
+	Fields      map[string]float64 `json:"fields"`
+	Recency     *RecencyBoost      `json:"recency,omitempty"`
+	// Quality adds the weight of each boolean field that is true.
+	Quality map[string]float64 `json:"quality,omitempty"`
+}
+
+type relevanceConfig struct {
+	Default  string                       `json:"default"`
+	Profiles map[string]*RelevanceProfile `json:"profiles"`
+}
+
+// relevance holds the active profiles. The embedded relevance.json is used
+// unless LoadRelevanceConfig replaces it at startup.
+var relevance = mustParseRelevanceConfig(defaultRelevanceConfig)
+
+func mustParseRelevanceConfig(data []byte) *relevanceConfig {
+	config, err := parseRelevanceConfig(data)
+	if err != nil {
+		panic(err)
+	}
+	return config
+}
+
+func parseRelevanceConfig(data []byte) (*relevanceConfig, error) {
+	var config relevanceConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing relevance config: %v", err)
+	}
+
+	if _, ok := config.Profiles[config.Default]; !ok {
+		return nil, fmt.Errorf("relevance config: default profile %q is not defined", config.Default)
+	}
+
+	for name, p := range config.Profiles {
+		if len(p.Fields) == 0 {
+			return nil, fmt.Errorf("relevance config: profile %q has no fields", name)
+		}
+		p.Operator = strings.ToUpper(p.Operator)
+		if p.Operator == "" {
+			p.Operator = "AND"
+		}
+		if p.Operator != "AND" && p.Operator != "OR" {
+			return nil, fmt.Errorf("relevance config: profile %q operator must be AND or OR", name)
+		}
+		if r := p.Recency; r != nil && (r.Field == "" || r.Scale == "" || r.Decay <= 0 || r.Decay >= 1) {
+			return nil, fmt.Errorf("relevance config: profile %q recency needs a field, a scale and a decay between 0 and 1", name)
+		}
+	}
+
+	return &config, nil
+}
+
+// LoadRelevanceConfig replaces the built-in relevance profiles with the
+// ones in the JSON file at path. It is meant to be called once at startup.
+//
+// Parameters:
+//   - path: Path of a file in the format of relevance.json
+//
+// Returns:
+//   - error: Error if the file cannot be read or is invalid
+func LoadRelevanceConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	config, err := parseRelevanceConfig(data)
+	if err != nil {
+		return err
+	}
+
+	relevance = config
+	return nil
+}
+
+// RelevanceProfiles returns the configured profiles by name and the name
+// of the default one.
+func RelevanceProfiles() (map[string]*RelevanceProfile, string) {
+	return relevance.Profiles, relevance.Default
+}
+
+// SetProfile selects the relevance profile used to score full-text hits.
+func (s *SearchParams) SetProfile(name string) error {
+	if _, ok := relevance.Profiles[name]; !ok && name != "" {
+		return fmt.Errorf("%w %q", ErrUnknownProfile, name)
+	}
+	s.profile = name
+	return nil
+}
+
+// relevanceProfile returns the selected profile or the default one.
+func (s *SearchParams) relevanceProfile() *RelevanceProfile {
+	if p, ok := relevance.Profiles[s.profile]; ok {
+		return p
+	}
+	return relevance.Profiles[relevance.Default]
+}
+
+// textQuery builds the full-text query over the profile's fields.
+func (p *RelevanceProfile) textQuery(queryString string) *elastic.SimpleQueryStringQuery {
+	fields := make([]string, 0, len(p.Fields))
+	for field := range p.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	query := elastic.NewSimpleQueryStringQuery(queryString)
+	for _, field := range fields {
+		query = query.FieldWithBoost(field, p.Fields[field])
+	}
+	return query.DefaultOperator(p.Operator)
+}
+
+// scoreQuery applies the profile's recency and quality boosts to query.
+// Each boost adds to a base multiplier of 1, so a boost never pushes a
+// matching contract below its text score.
+func (p *RelevanceProfile) scoreQuery(query elastic.Query) elastic.Query {
+	if p.Recency == nil && len(p.Quality) == 0 {
+		return query
+	}
+
+	scored := elastic.NewFunctionScoreQuery().
+		Query(query).
+		AddScoreFunc(elastic.NewWeightFactorFunction(1)).
+		ScoreMode("sum").
+		BoostMode("multiply")
+
+	if r := p.Recency; r != nil {
+		decay := elastic.NewGaussDecayFunction().FieldName(r.Field).Origin("now").Scale(r.Scale).Decay(r.Decay).Weight(r.Weight)
+		if r.Offset != "" {
+			decay = decay.Offset(r.Offset)
+		}
+		scored = scored.AddScoreFunc(decay)
+	}
+
+	fields := make([]string, 0, len(p.Quality))
+	for field := range p.Quality {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		scored = scored.Add(elastic.NewTermQuery(field, true), elastic.NewWeightFactorFunction(p.Quality[field]))
+	}
+
+	return scored
+}
@@ -0,0 +1,123 @@
+package queries
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRelevanceOrdering(t *testing.T) {
+	f := newFakeElastic(t, "testdata/relevance_contracts.json")
+
+	tests := []struct {
+		name    string
+		profile string
+		q       string
+		want    string
+	}{
+		{name: "contract name outranks body text", profile: "default", q: "royalty", want: "royalty-name,royalty-deep"},
+		{name: "flat profile lets body text win", profile: "flat", q: "royalty", want: "royalty-deep,royalty-name"},
+		{name: "recent contracts rank higher", profile: "default", q: "tailings", want: "tailings-recent,tailings-old"},
+		{name: "reviewed text ranks higher", profile: "default", q: "water", want: "water-reviewed,water-unreviewed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := searchFixture(t, f, url.Values{"q": {tt.q}, "profile": {tt.profile}, "transliterate": {"false"}})
+			want := strings.Split(tt.want, ",")
+			if len(ids) < len(want) || strings.Join(ids[:len(want)], ",") != tt.want {
+				t.Errorf("ranking for %q with %s = %v, want %s first", tt.q, tt.profile, ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelevanceQuery(t *testing.T) {
+	custom := mustParseRelevanceConfig([]byte(`{"default": "custom", "profiles": {"custom": {
+		"operator": "OR",
+		"fields": {"metadata.contract_name": 4, "pdf_text_string": 0.5},
+		"recency": {"field": "metadata.signature_date", "scale": "30d", "decay": 0.25, "weight": 2}
+	}}}`))
+
+	tests := []struct {
+		name    string
+		config  *relevanceConfig
+		profile string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "default profile boosts names, recent and reviewed contracts",
+			config:  relevance,
+			profile: "default",
+			want: []string{
+				`"boost_mode":"multiply"`, `"score_mode":"sum"`, `{"weight":1}`,
+				`"default_operator":"and"`, `"metadata.contract_name^5.000000"`, `"pdf_text_string^1.000000"`,
+				`"gauss":{"metadata.signature_date":{"decay":0.5,"offset":"365d","origin":"now","scale":"1825d"}},"weight":0.5`,
+				`{"filter":{"term":{"metadata.is_ocr_reviewed":true}},"weight":0.3}`,
+				`{"filter":{"term":{"metadata.show_pdf_text":true}},"weight":0.2}`,
+			},
+		},
+		{
+			name:    "flat profile has no function_score",
+			config:  relevance,
+			profile: "flat",
+			want:    []string{`"metadata.contract_name^1.000000"`, `"pdf_text_string^1.000000"`},
+			notWant: []string{`"function_score"`},
+		},
+		{
+			name:    "configured profile without offset or quality boosts",
+			config:  custom,
+			want:    []string{`"default_operator":"or"`, `"fields":["metadata.contract_name^4.000000","pdf_text_string^0.500000"]`, `"gauss":{"metadata.signature_date":{"decay":0.25,"origin":"now","scale":"30d"}},"weight":2`},
+			notWant: []string{`"offset"`, `"filter"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := relevance
+			t.Cleanup(func() { relevance = saved })
+			relevance = tt.config
+
+			f := newFakeElastic(t, "testdata/company_contracts.json")
+			values := url.Values{"q": {"royalty"}, "transliterate": {"false"}}
+			if tt.profile != "" {
+				values.Set("profile", tt.profile)
+			}
+			searchFixture(t, f, values)
+
+			got, _ := json.Marshal(f.searches[0]["query"])
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("query = %s, want it to contain %s", got, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(string(got), notWant) {
+					t.Errorf("query = %s, want no %s", got, notWant)
+				}
+			}
+		})
+	}
+
+	params := NewSearchParams("royalty", "", "", "", "", "", "")
+	if err := params.SetProfile("nope"); err == nil {
+		t.Errorf("SetProfile(nope) returned no error")
+	}
+}
+
+func TestParseRelevanceConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{name: "missing default", config: `{"default": "x", "profiles": {"y": {"fields": {"a": 1}}}}`},
+		{name: "no fields", config: `{"default": "x", "profiles": {"x": {}}}`},
+		{name: "bad operator", config: `{"default": "x", "profiles": {"x": {"operator": "XOR", "fields": {"a": 1}}}}`},
+		{name: "bad decay", config: `{"default": "x", "profiles": {"x": {"fields": {"a": 1}, "recency": {"field": "d", "scale": "10d", "decay": 2}}}}`},
+	}
+	for _, tt := range tests {
+		if _, err := parseRelevanceConfig([]byte(tt.config)); err == nil {
+			t.Errorf("%s: parseRelevanceConfig() returned no error", tt.name)
+		}
+	}
+}
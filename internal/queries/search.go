@@ -1,334 +1,481 @@
 package queries
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	appcontext "iltodgeree/api/internal/app_context"
+	"iltodgeree/api/internal/correction"
 	"iltodgeree/api/internal/structs"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
+
+	"gopkg.in/olivere/elastic.v5"
 )
 
-type Highlight struct {
-	FragmentSize      uint16 `json:"fragmant_size"`
-	NumberOfFragments uint16 `json:"number_of_fragments"`
-}
+// Defaults of the legacy search, as in the resourcecontracts API.
+const (
+	legacyDefaultPerPage = 25
+	legacyMaxPerPage     = 100
+)
 
-type HighlightFields struct {
-	MetadataString    Highlight
-	PdfTextString     Highlight
-	AnnotationsString Highlight
+// legacyGroups maps the legacy group values to the field they search and
+// the label a hit gets when that field matched.
+var legacyGroups = map[string]struct{ field, label string }{
+	"metadata":    {"metadata_string", "Metadata"},
+	"text":        {"pdf_text_string", "Text"},
+	"annotations": {"annotations_string", "Annotation"},
 }
 
-type Query map[string]interface{}
-type QueryFilters map[string]interface{}
+// legacyGroupOrder lists the groups in the order they are reported.
+var legacyGroupOrder = []string{"metadata", "text", "annotations"}
 
-type Bool struct {
-	Should interface{} `json:"should"`
+// legacySortFields maps the legacy sort_by values to sort fields.
+var legacySortFields = map[string]string{
+	"country":       "metadata.country_name.keyword",
+	"year":          "metadata.signature_date",
+	"contract_name": "metadata.contract_name.keyword",
+	"resource":      "metadata.resource_raw.keyword",
+	"contract_type": "metadata.contract_type.keyword",
 }
 
-type SimpleQueryString struct {
-	Fields          []string `json:"fields"`
-	Query           string   `json:"query"`
-	DefaultOperator string   `json:"default_operator"`
+// legacyAggregations maps the facet lists of the legacy response to the
+// field they are read from.
+var legacyAggregations = map[string]string{
+	"country":         "metadata.country_code.keyword",
+	"year":            "metadata.signature_year.keyword",
+	"resource":        "metadata.resource.keyword",
+	"contract_type":   "metadata.contract_type.keyword",
+	"company_name":    "metadata.company_name.keyword",
+	"corporate_group": "metadata.corporate_grouping.keyword",
 }
 
-type FullTextQuery struct {
-	Size      uint16                 `json:"size"`
-	From      uint16                 `json:"from"`
-	Query     Query                  `json:"query"`
-	Highlight map[string]interface{} `json:"highlight"`
-	Filter    QueryFilters           `json:"filter"`
-	Sort      map[string]interface{} `json:"sort"`
+// Result is one hit in the legacy response. Metadata, Text and Annotations
+// hold the highlighted fragments of the fields that matched q, and Group
+// names those fields.
+type Result struct {
+	ID                string   `json:"id"`
+	OpenContractingID string   `json:"open_contracting_id"`
+	Name              string   `json:"name"`
+	YearSigned        string   `json:"year_signed"`
+	ContractType      []string `json:"contract_type"`
+	Resource          []string `json:"resource"`
+	CountryCode       string   `json:"country_code"`
+	Language          string   `json:"language"`
+	Category          []string `json:"category"`
+	IsOcrReviewed     bool     `json:"is_ocr_reviewed"`
+	Metadata          string   `json:"metadata"`
+	Annotations       string   `json:"annotations"`
+	Text              string   `json:"text"`
+	Group             []string `json:"group"`
 }
 
-type Annotation struct{}
-type Group interface{}
-
-type Result struct {
-	ID                string       `json:"id"`
-	OpenContractingID string       `json:"open_contracting_id"`
-	Name              string       `json:"name"`
-	YearSigned        string       `json:"year_signed"`
-	ContractType      string       `json:"contract_type"`
-	Resource          string       `json:"resource"`
-	CountryCode       string       `json:"country_code"`
-	Language          string       `json:"language"`
-	Category          string       `json:"category"`
-	IsOcrReviewed     bool         `json:"is_ocr_reviewed"`
-	Metadata          string       `json:"metadata"`
-	Annotations       []Annotation `json:"annotations"`
-	Text              string       `json:"text"`
-	Group             Group        `json:"group"`
+// LegacySearchResponse is the response of the resourcecontracts-style
+// full-text search. The facet lists hold the values found in all hits.
+type LegacySearchResponse struct {
+	Total          int64    `json:"total"`
+	From           int      `json:"from"`
+	PerPage        int      `json:"per_page"`
+	Results        []Result `json:"results"`
+	Country        []string `json:"country"`
+	Year           []string `json:"year"`
+	Resource       []string `json:"resource"`
+	ContractType   []string `json:"contract_type"`
+	CompanyName    []string `json:"company_name"`
+	CorporateGroup []string `json:"corporate_group"`
+	// SearchResult is the raw result, used for downloads.
+	SearchResult *elastic.SearchResult `json:"-"`
 }
 
-func SearchInMaster(args structs.FullTextSearchArguments) {
-	// var documentType = "master"
-	var filters []map[string]interface{}
-	var phrases []map[string]interface{}
-
-	var types []string
-	splitGroups := strings.Split(*args.Group, ",")
-	for _, g := range splitGroups {
-		types = append(types, strings.TrimSpace(g))
-	}
-
-	// filters
-	if args.Year != nil {
-		filters = append(filters, map[string]interface{}{
-			"terms": map[string]interface{}{
-				"metadata.signature_year": strings.Split(*args.Year, ","),
-			},
-		})
-	}
-	if args.CountryCode != nil {
-		filters = append(filters, map[string]interface{}{
-			"terms": map[string]interface{}{
-				"metadata.country_code": strings.Split(*args.CountryCode, ","),
-			},
-		})
-	}
-	if args.Resource != nil {
-		filters = append(filters, map[string]interface{}{
-			"terms": map[string]interface{}{
-				"metadata.resource_raw": strings.Split(*args.Resource, ","),
-			},
-		})
-	}
-	if args.Category != nil {
-		filters = append(filters, map[string]interface{}{
-			"terms": map[string]interface{}{
-				"metadata.category": strings.Split(*args.Category, ","),
-			},
-		})
-	}
-	if args.CorporateGroup != nil {
-		for _, lang := range strings.Split(*args.CorporateGroup, ",") {
-			item := map[string]interface{}{
-				"terms": map[string]interface{}{
-					"metadata.corporate_grouping": lang,
-				},
-			}
-			filters = append(filters, item)
+// LegacyArgumentsFromValues reads the parameters of the legacy search.
+// Parameters left empty stay nil.
+//
+// Parameters:
+//   - values: Query string of the request
+//
+// Returns:
+//   - structs.FullTextSearchArguments: The parsed arguments
+//   - error: Error describing the first invalid parameter
+func LegacyArgumentsFromValues(values url.Values) (structs.FullTextSearchArguments, error) {
+	args := structs.FullTextSearchArguments{}
+
+	for name, target := range map[string]**string{
+		"year":                &args.Year,
+		"country_code":        &args.CountryCode,
+		"resource":            &args.Resource,
+		"category":            &args.Category,
+		"contract_type":       &args.ContractType,
+		"document_type":       &args.DocumentType,
+		"language":            &args.Language,
+		"company_name":        &args.CompanyName,
+		"corporate_group":     &args.CorporateGroup,
+		"annotation_category": &args.AnnotationCategory,
+		"province":            &args.Province,
+		"district":            &args.District,
+		"project":             &args.Project,
+		"government":          &args.Government,
+		"q":                   &args.Q,
+		"sort_by":             &args.SortBy,
+		"order":               &args.Order,
+		"group":               &args.Group,
+	} {
+		if value := strings.TrimSpace(values.Get(name)); value != "" {
+			*target = &value
 		}
 	}
-	if args.Annotated != nil {
-		annotated := map[string]interface{}{
-			"bool": map[string]interface{}{
-				"must_not": map[string]interface{}{
-					"missing": map[string]interface{}{
-						"field":     "annotations_string",
-						"existence": true,
-					},
-				},
-			},
+
+	for name, target := range map[string]**bool{"annotated": &args.Annotated, "download": &args.Download} {
+		if values.Get(name) == "" {
+			continue
 		}
-		filters = append(filters, annotated)
+		value, err := strconv.ParseBool(values.Get(name))
+		if err != nil {
+			return args, fmt.Errorf("%s: boolean утгыг хөрвүүлж чадсангүй", name)
+		}
+		*target = &value
 	}
-	if args.Province != nil {
-		item := map[string]interface{}{
-			"terms": map[string]interface{}{
-				"metadata.provinces.province": strings.Split(*args.Province, ","),
-			},
+
+	for name, target := range map[string]**uint16{"from": &args.From, "per_page": &args.PerPage} {
+		if values.Get(name) == "" {
+			continue
 		}
-		filters = append(filters, item)
+		value, err := strconv.ParseUint(values.Get(name), 10, 16)
+		if err != nil {
+			return args, fmt.Errorf("%s must be a non-negative number", name)
+		}
+		v := uint16(value)
+		*target = &v
+	}
+
+	if args.PerPage != nil && (*args.PerPage < 1 || *args.PerPage > legacyMaxPerPage) {
+		return args, fmt.Errorf("per_page must be between 1 and %d", legacyMaxPerPage)
+	}
+	if args.From != nil && int(*args.From)+legacyPerPage(args) > maxGroupedHits {
+		return args, fmt.Errorf("from + per_page must not exceed %d", maxGroupedHits)
+	}
+
+	if args.SortBy != nil {
+		if _, ok := legacySortFields[*args.SortBy]; !ok {
+			return args, fmt.Errorf("sort_by must be country, year, contract_name, resource or contract_type")
+		}
+	}
+	if args.Order != nil && *args.Order != "asc" && *args.Order != "desc" {
+		return args, fmt.Errorf("order must be asc or desc")
 	}
-	if args.District != nil {
-		item := map[string]interface{}{
-			"terms": map[string]interface{}{
-				"metadata.district_id": strings.Split(*args.Province, ","),
-			},
+	for _, g := range splitValues(args.Group) {
+		if _, ok := legacyGroups[g]; !ok {
+			return args, fmt.Errorf("group must list metadata, text or annotations")
 		}
-		filters = append(filters, item)
 	}
 
-	// phrases
-	if args.ContractType != nil {
-		contractTypes := strings.Split(*args.ContractType, ",")
+	return args, nil
+}
 
-		for _, contractType := range contractTypes {
-			item := map[string]interface{}{
-				"match_phrase": map[string]interface{}{
-					"metadata.contract_type": contractType,
-				},
-			}
-			phrases = append(phrases, item)
+// splitValues splits a comma-separated parameter, dropping empty values.
+func splitValues(value *string) []string {
+	if value == nil {
+		return nil
+	}
+
+	var values []string
+	for _, v := range strings.Split(*value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
 		}
 	}
+	return values
+}
+
+func legacyPerPage(args structs.FullTextSearchArguments) int {
+	if args.PerPage == nil {
+		return legacyDefaultPerPage
+	}
+	return int(*args.PerPage)
+}
 
-	if args.DocumentType != nil {
-		documentTypes := strings.Split(*args.DocumentType, ",")
+func legacyFrom(args structs.FullTextSearchArguments) int {
+	if args.From == nil {
+		return 0
+	}
+	return int(*args.From)
+}
+
+// legacyFields returns the fields q searches, by default all groups.
+func legacyFields(args structs.FullTextSearchArguments) []string {
+	groups := splitValues(args.Group)
+	if len(groups) == 0 {
+		groups = legacyGroupOrder
+	}
 
-		for _, documentType := range documentTypes {
-			item := map[string]interface{}{
-				"match_phrase": map[string]interface{}{
-					"metadata.document_type": documentType,
-				},
+	var fields []string
+	for _, g := range legacyGroupOrder {
+		for _, selected := range groups {
+			if g == selected {
+				fields = append(fields, legacyGroups[g].field)
+				break
 			}
-			phrases = append(phrases, item)
 		}
 	}
+	return fields
+}
 
-	if args.Language != nil {
-		for _, lang := range strings.Split(*args.Language, ",") {
-			item := map[string]interface{}{
-				"match_phrase": map[string]interface{}{
-					"metadata.language": lang,
-				},
-			}
-			phrases = append(phrases, item)
+// legacyQuery builds the query of the legacy search. Values of one
+// parameter are alternatives, the parameters all have to match.
+func legacyQuery(args structs.FullTextSearchArguments) elastic.Query {
+	query := elastic.NewBoolQuery()
+
+	terms := func(field string, values []string) {
+		if len(values) > 0 {
+			query = query.Filter(elastic.NewTermsQuery(field, interfaces(values)...))
 		}
 	}
 
-	if args.CompanyName != nil {
-		for _, lang := range strings.Split(*args.CompanyName, ",") {
-			item := map[string]interface{}{
-				"match_phrase": map[string]interface{}{
-					"metadata.company_name": lang,
-				},
-			}
-			phrases = append(phrases, item)
+	terms("metadata.signature_year", splitValues(args.Year))
+	terms("metadata.country_code.keyword", splitValues(args.CountryCode))
+	terms("metadata.category.keyword", splitValues(args.Category))
+	terms("metadata.language.keyword", splitValues(args.Language))
+	terms("metadata.company_name.keyword", splitValues(args.CompanyName))
+	terms("metadata.corporate_grouping.keyword", splitValues(args.CorporateGroup))
+	terms("metadata.provinces.province", splitValues(args.Province))
+	terms("metadata.provinces.district", splitValues(args.District))
+
+	var resources []string
+	for _, r := range splitValues(args.Resource) {
+		if ids := resourceIDs(r); len(ids) > 0 {
+			resources = append(resources, ids...)
+			continue
 		}
+		resources = append(resources, r)
 	}
+	terms("metadata.resource", resources)
 
-	if args.AnnotationCategory != nil {
-		for _, lang := range strings.Split(*args.AnnotationCategory, ",") {
-			item := map[string]interface{}{
-				"match_phrase": map[string]interface{}{
-					"annotations_category": lang,
-				},
-			}
-			phrases = append(phrases, item)
+	var contractTypes []string
+	for _, t := range splitValues(args.ContractType) {
+		if names := englishNames(t, correction.ContractTypes, correction.ContractTypesReverse); len(names) > 0 {
+			contractTypes = append(contractTypes, names...)
+			continue
 		}
+		contractTypes = append(contractTypes, t)
 	}
+	terms("metadata.contract_type.keyword", contractTypes)
 
-	if args.Project != nil {
-		item := map[string]interface{}{
-			"match": map[string]interface{}{
-				"metadata.project_title": map[string]interface{}{
-					"query":    *args.Project,
-					"operator": "AND",
-				},
-			},
+	var documentTypes []string
+	for _, t := range splitValues(args.DocumentType) {
+		if names := englishNames(t, correction.DocumentTypes, correction.DocumentTypesReverse); len(names) > 0 {
+			documentTypes = append(documentTypes, names...)
+			continue
 		}
-		phrases = append(phrases, item)
+		documentTypes = append(documentTypes, t)
 	}
+	terms("metadata.document_type.keyword", documentTypes)
 
-	if args.Government != nil {
-		item := map[string]interface{}{
-			"match": map[string]interface{}{
-				"metadata.government_entity.entity": map[string]interface{}{
-					"query":    *args.Government,
-					"operator": "AND",
-				},
-			},
+	if categories := splitValues(args.AnnotationCategory); len(categories) > 0 {
+		phrases := []elastic.Query{}
+		for _, c := range categories {
+			phrases = append(phrases, elastic.NewMatchPhraseQuery("annotations_category", c))
+		}
+		query = query.Filter(elastic.NewBoolQuery().Should(phrases...).MinimumNumberShouldMatch(1))
+	}
+
+	if args.Annotated != nil {
+		exists := elastic.NewExistsQuery("annotations_string")
+		if *args.Annotated {
+			query = query.Filter(exists)
+		} else {
+			query = query.MustNot(exists)
 		}
-		phrases = append(phrases, item)
-	}
-
-	fields := []string{
-		"metadata.contract_name",
-		"metadata.signature_year",
-		"metadata.open_contracting_id",
-		"metadata.signature_date",
-		"metadata.file_size",
-		"metadata.country_code",
-		"metadata.country_name",
-		"metadata.resource",
-		"metadata.language",
-		"metadata.file_size",
-		"metadata.company_name",
-		"metadata.contract_type",
-		"metadata.corporate_grouping",
-		"metadata.show_pdf_text",
-		"metadata.category",
-		"metadata.district_id",
-		"metadata.provinces.province",
-		"metadata_string",
-		"pdf_text_string",
-		"annotations_string",
-	}
-
-	sorts := make(map[string]interface{})
-	order := *args.Order
-
-	if *args.SortBy == "country" {
-		sorts["metadata.country_name"].(map[string]interface{})["order"] = order
-	}
-	if *args.SortBy == "year" {
-		sorts["metadata.year"].(map[string]interface{})["order"] = order
-	}
-	if *args.SortBy == "contract_name" {
-		sorts["metadata.contract_name"].(map[string]interface{})["order"] = order
-	}
-	if *args.SortBy == "resource" {
-		sorts["metadata.resource"].(map[string]interface{})["order"] = order
-	}
-	if *args.SortBy == "contract_type" {
-		sorts["metadata.contract_type"].(map[string]interface{})["order"] = order
-	}
-
-	highlights := HighlightFields{
-		MetadataString: Highlight{
-			FragmentSize:      200,
-			NumberOfFragments: 1,
-		},
-		PdfTextString: Highlight{
-			FragmentSize:      200,
-			NumberOfFragments: 50,
-		},
-		AnnotationsString: Highlight{
-			FragmentSize:      50,
-			NumberOfFragments: 1,
-		},
-	}
-
-	bodyHighlight := map[string]interface{}{
-		"pre_tags":  []string{"<strong>"},
-		"post_tags": []string{"</strong>"},
-		"fields":    highlights,
-	}
-
-	var query map[string]interface{}
-	var queryFilters map[string]interface{}
-
-	if len(phrases) > 0 && (*args.Q == "" || args.Q == nil) {
-		query = map[string]interface{}{
-			"bool": Bool{
-				Should: phrases,
-			},
+	}
+
+	if args.Project != nil {
+		query = query.Filter(elastic.NewMatchQuery("metadata.project_title", *args.Project).Operator("and"))
+	}
+
+	if args.Government != nil {
+		query = query.Filter(elastic.NewMatchQuery("metadata.government_entity.entity", *args.Government).Operator("and"))
+	}
+
+	if args.Q != nil {
+		fts := elastic.NewSimpleQueryStringQuery(*args.Q).DefaultOperator("AND")
+		for _, field := range legacyFields(args) {
+			fts = fts.Field(field)
 		}
+		query = query.Must(fts)
 	}
 
-	if *args.Q != "" && args.Q != nil {
-		query = map[string]interface{}{
-			"simple_query_string": SimpleQueryString{
-				Fields:          fields,
-				Query:           *args.Q,
-				DefaultOperator: "AND",
-			},
+	return query
+}
+
+// legacySearchSource builds the full request of the legacy search.
+func legacySearchSource(args structs.FullTextSearchArguments) *elastic.SearchSource {
+	highlight := elastic.NewHighlight().PreTags("<strong>").PostTags("</strong>").Fields(
+		elastic.NewHighlighterField("metadata_string").FragmentSize(200).NumOfFragments(1),
+		elastic.NewHighlighterField("pdf_text_string").FragmentSize(200).NumOfFragments(50),
+		elastic.NewHighlighterField("annotations_string").FragmentSize(50).NumOfFragments(1),
+	)
+
+	source := elastic.NewSearchSource().
+		Query(legacyQuery(args)).
+		Highlight(highlight).
+		From(legacyFrom(args)).
+		Size(legacyPerPage(args))
+
+	asc := args.Order != nil && *args.Order == "asc"
+	switch {
+	case args.SortBy != nil:
+		source = source.Sort(legacySortFields[*args.SortBy], asc)
+	case args.Q != nil:
+		source = source.Sort("_score", false)
+	default:
+		source = source.Sort("metadata.signature_date", false)
+	}
+	source = source.Sort(tiebreakerField, true)
+
+	for name, field := range legacyAggregations {
+		source = source.Aggregation(name, elastic.NewTermsAggregation().Field(field).Size(10000))
+	}
+
+	return source
+}
+
+// legacyResult converts a hit to the legacy result shape.
+func legacyResult(hit *elastic.SearchHit) Result {
+	var doc struct {
+		Metadata map[string]interface{} `json:"metadata"`
+	}
+	if hit.Source != nil {
+		_ = json.Unmarshal(*hit.Source, &doc)
+	}
+
+	first := func(name string) string {
+		if values := stringValues(doc.Metadata[name]); len(values) > 0 {
+			return values[0]
 		}
+		return ""
 	}
 
-	if len(filters) > 0 {
-		queryFilters = map[string]interface{}{
-			"filter": map[string]interface{}{
-				"and": map[string]interface{}{
-					"filters": filters,
-				},
-			},
+	result := Result{
+		ID:                hit.Id,
+		OpenContractingID: first("open_contracting_id"),
+		Name:              first("contract_name"),
+		YearSigned:        first("signature_year"),
+		ContractType:      stringValues(doc.Metadata["contract_type"]),
+		Resource:          []string{},
+		CountryCode:       first("country_code"),
+		Language:          first("language"),
+		Category:          stringValues(doc.Metadata["category"]),
+		Group:             []string{},
+	}
+	if result.ContractType == nil {
+		result.ContractType = []string{}
+	}
+	if result.Category == nil {
+		result.Category = []string{}
+	}
+	result.IsOcrReviewed, _ = doc.Metadata["is_ocr_reviewed"].(bool)
+
+	for _, id := range stringValues(doc.Metadata["resource"]) {
+		result.Resource = append(result.Resource, resourceLabel(id))
+	}
+
+	for _, g := range legacyGroupOrder {
+		fragments, ok := hit.Highlight[legacyGroups[g].field]
+		if !ok {
+			continue
 		}
+		text := strings.Join(fragments, " ... ")
+		switch g {
+		case "metadata":
+			result.Metadata = text
+		case "text":
+			result.Text = text
+		case "annotations":
+			result.Annotations = text
+		}
+		result.Group = append(result.Group, legacyGroups[g].label)
 	}
 
-	body := FullTextQuery{
-		Size:      *args.PerPage,
-		From:      *args.From,
-		Query:     query,
-		Filter:    queryFilters,
-		Highlight: bodyHighlight,
-		Sort:      sorts,
+	return result
+}
+
+// resourceLabel returns the Mongolian name of a resource ID, or the ID when
+// it is unknown.
+func resourceLabel(id string) string {
+	if label, ok := correction.Resources[id]; ok {
+		return label
 	}
+	return id
+}
 
-	fmt.Println(body)
+// aggregationKeys lists the bucket keys of a terms aggregation.
+func aggregationKeys(aggs elastic.Aggregations, name string, label func(string) string) []string {
+	keys := []string{}
+	terms, ok := aggs.Terms(name)
+	if !ok {
+		return keys
+	}
+	for _, bucket := range terms.Buckets {
+		key := fmt.Sprint(bucket.Key)
+		if label != nil {
+			key = label(key)
+		}
+		keys = append(keys, key)
+	}
+	return keys
 }
 
-func Search() {
+// SearchInMaster runs the resourcecontracts-style full-text search over the
+// master documents, for integrations written against the legacy API.
+//
+// Parameters:
+//   - args: Arguments from LegacyArgumentsFromValues
+//
+// Returns:
+//   - *LegacySearchResponse: Hits in the legacy shape with the facet lists
+//   - error: Error if the search fails
+func SearchInMaster(args structs.FullTextSearchArguments) (*LegacySearchResponse, error) {
+	client, err := appcontext.ElasticInstance.GetV5()
+	if err != nil {
+		panic(err)
+	}
+
+	index := os.Getenv("ELASTICSEARCH_SECONDARY")
+	docType := os.Getenv("ELASTICSEARCH_DOC_MASTER")
+	source := legacySearchSource(args)
+
+	body, err := source.Source()
+	if err != nil {
+		return nil, fmt.Errorf("error building search: %v", err)
+	}
+	slog.Debug("legacy search request", "index", index, "type", docType, "body", body)
+
+	result, err := client.Search().
+		Index(index).
+		Type(docType).
+		SearchSource(source).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search: %v", err)
+	}
+
+	response := &LegacySearchResponse{
+		Total:          result.Hits.TotalHits,
+		From:           legacyFrom(args),
+		PerPage:        legacyPerPage(args),
+		Results:        []Result{},
+		Country:        aggregationKeys(result.Aggregations, "country", nil),
+		Year:           aggregationKeys(result.Aggregations, "year", nil),
+		Resource:       aggregationKeys(result.Aggregations, "resource", resourceLabel),
+		ContractType:   aggregationKeys(result.Aggregations, "contract_type", nil),
+		CompanyName:    aggregationKeys(result.Aggregations, "company_name", nil),
+		CorporateGroup: aggregationKeys(result.Aggregations, "corporate_group", nil),
+		SearchResult:   result,
+	}
+	for _, hit := range result.Hits.Hits {
+		response.Results = append(response.Results, legacyResult(hit))
+	}
 
+	return response, nil
 }
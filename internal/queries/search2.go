@@ -4,14 +4,14 @@ package queries
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	appcontext "iltodgeree/api/internal/app_context"
-	"iltodgeree/api/internal/correction"
 	"log"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/olivere/elastic.v5"
 )
@@ -25,20 +25,77 @@ type SearchParams struct {
 	q                  string
 	years              []interface{}
 	resources          []interface{}
-	companies          string
-	governments        string
+	companies          []interface{}
+	governments        []interface{}
 	contractTypes      []interface{}
 	documentTypes      []interface{}
 	annotationCategory []interface{}
-	annotated          bool
+	annotationMatch    string
+	annotated          *bool
+	facets             bool
+	autoCorrect        bool
+	transliterate      bool
 	province           string
 	district           []interface{}
 	size               int
 	from               int
+	cursor             *cursor
+	createdAfter       *time.Time
+	signedFrom         *time.Time
+	signedTo           *time.Time
+	createdFrom        *time.Time
+	createdTo          *time.Time
+	excludes           map[string][]interface{}
+	fieldClauses       []fieldClause
+	groupBy            string
+	profile            string
+	debug              bool
 	sortBy             *string
 	order              *bool
 }
 
+// SearchResponse is the Elasticsearch search result extended with the
+// values computed for /api/search. It serializes as the plain result plus
+// the extra keys.
+type SearchResponse struct {
+	*elastic.SearchResult
+	Facets     map[string][]FacetBucket `json:"facets,omitempty"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+	// DidYouMean is the spelling correction of q, if any.
+	DidYouMean string `json:"did_you_mean,omitempty"`
+	// OriginalQuery is set when the hits are for the corrected query.
+	OriginalQuery string `json:"original_query,omitempty"`
+	// Groups holds the page of groups when the hits are grouped; hits then
+	// lists the top hit of each group.
+	Groups      []SearchGroup `json:"groups,omitempty"`
+	TotalGroups *int          `json:"total_groups,omitempty"`
+	// GroupsCapped is set when more hits matched than could be grouped, so
+	// TotalGroups only counts the groups of the first maxGroupedHits hits.
+	GroupsCapped bool `json:"groups_capped,omitempty"`
+	// Debug is only set for debug searches; the score explanation of each
+	// hit is in its _explanation.
+	Debug *SearchDebug `json:"debug,omitempty"`
+	// SearchID identifies the logged search; clicks on its hits are
+	// reported with it to /api/search/clicks.
+	SearchID int64 `json:"search_id,omitempty"`
+}
+
+// SearchDebug describes how a debug search was run.
+type SearchDebug struct {
+	Request interface{}            `json:"request"`
+	Timing  SearchTiming           `json:"timing"`
+	Profile *elastic.SearchProfile `json:"profile,omitempty"`
+}
+
+// SearchTiming breaks the time of a search down, in milliseconds.
+type SearchTiming struct {
+	BuildMs         float64 `json:"build_ms"`
+	GroupingMs      float64 `json:"grouping_ms,omitempty"`
+	ElasticsearchMs float64 `json:"elasticsearch_ms"`
+	RoundTripMs     float64 `json:"round_trip_ms"`
+	TotalMs         float64 `json:"total_ms"`
+}
+
 // NewSearchParams creates a new SearchParams instance with the provided filter values.
 // It parses comma-separated strings into arrays for multi-value filters.
 //
@@ -47,8 +104,8 @@ type SearchParams struct {
 //   - years: Comma-separated list of years
 //   - contractTypes: Comma-separated list of contract types
 //   - resources: Comma-separated list of resource types
-//   - companies: Company name filter
-//   - governments: Government entity filter
+//   - companies: Comma-separated list of company names (see splitNames), a trailing * matches by prefix
+//   - governments: Comma-separated list of government entities, like companies
 //   - documentTypes: Comma-separated list of document types
 //
 // Returns:
@@ -66,8 +123,8 @@ func NewSearchParams(
 	var _years []interface{}
 	var _contractTypes []interface{}
 	var _resources []interface{}
-	var _companies string
-	var _governments string
+	var _companies []interface{}
+	var _governments []interface{}
 	var _documentTypes []interface{}
 
 	if years != "" {
@@ -93,12 +150,12 @@ func NewSearchParams(
 		}
 	}
 
-	if companies != "" {
-		_companies = companies
+	for _, part := range splitNames(companies) {
+		_companies = append(_companies, part)
 	}
 
-	if governments != "" {
-		_governments = governments
+	for _, part := range splitNames(governments) {
+		_governments = append(_governments, part)
 	}
 
 	if documentTypes != "" {
@@ -115,6 +172,7 @@ func NewSearchParams(
 		companies:     _companies,
 		governments:   _governments,
 		documentTypes: _documentTypes,
+		transliterate: true,
 		sortBy:        new(string),
 		order:         new(bool),
 	}
@@ -146,8 +204,25 @@ func (s *SearchParams) SetAnnotationCategories(categories string) {
 	}
 }
 
+// SetAnnotationMatch sets whether contracts need "any" (the default) or
+// "all" of the annotation categories.
+func (s *SearchParams) SetAnnotationMatch(match string) error {
+	if match != "" && match != "any" && match != "all" {
+		return fmt.Errorf("annotation_category_match must be any or all")
+	}
+	s.annotationMatch = match
+	return nil
+}
+
+// SetAnnotated restricts the search to contracts with (true) or without
+// (false) annotations.
 func (s *SearchParams) SetAnnotated(annotated bool) {
-	s.annotated = annotated
+	s.annotated = &annotated
+}
+
+// SetFacets enables per-facet counts in the search response.
+func (s *SearchParams) SetFacets(facets bool) {
+	s.facets = facets
 }
 
 func (s *SearchParams) SetSortBy(sortBy string) {
@@ -163,7 +238,15 @@ func (s *SearchParams) SetSortBy(sortBy string) {
 			*s.sortBy = "metadata.resource_raw.keyword"
 		} else if sortBy == "contract_type" {
 			*s.sortBy = "metadata.contract_type.keyword"
+		} else if sortBy == "created_at" {
+			*s.sortBy = "created_at"
+		} else if sortBy == "relevance" {
+			*s.sortBy = "_score"
 		}
+	} else if s.q != "" || len(s.fieldClauses) > 0 {
+		// Full-text searches rank by relevance unless a sort is asked for.
+		*s.sortBy = "_score"
+		*s.order = false
 	} else {
 		*s.sortBy = "metadata.signature_date"
 		*s.order = false
@@ -202,144 +285,220 @@ func (s *SearchParams) SetFrom(from string) {
 	}
 }
 
-// SearchV2 executes a comprehensive search query against Elasticsearch.
-// It builds a bool query with filters, performs full-text search if specified,
-// applies highlights, sorting, and pagination.
-//
-// Parameters:
-//   - params: SearchParams object containing all search criteria
-//
-// Returns:
-//   - *elastic.SearchResult: Search results from Elasticsearch
-//   - *error: Error if the search fails
-func SearchV2(params *SearchParams) (*elastic.SearchResult, *error) {
-	client, err := appcontext.ElasticInstance.GetV5()
-	if err != nil {
-		panic(err)
-	}
+// SetAutoCorrect re-runs a search that found nothing with its did-you-mean
+// correction.
+func (s *SearchParams) SetAutoCorrect(autoCorrect bool) {
+	s.autoCorrect = autoCorrect
+}
 
-	boolQuery := elastic.NewBoolQuery()
+// SetTransliterate toggles the expansion of q and the company and
+// government filters into Cyrillic/Latin and keyboard-layout variants.
+// It is on by default.
+func (s *SearchParams) SetTransliterate(transliterate bool) {
+	s.transliterate = transliterate
+}
 
-	filters := []elastic.Query{}
-	phrases := []elastic.Query{}
+// SetCreatedAfter limits the search to contracts created after t.
+func (s *SearchParams) SetCreatedAfter(t time.Time) {
+	s.createdAfter = &t
+}
 
-	if len(params.years) != 0 {
-		filters = append(filters, elastic.NewTermsQuery("metadata.signature_year", params.years...))
-	}
+// SetSignedRange limits the search to contracts signed between from and to,
+// both inclusive. Either bound may be nil for an open-ended range.
+func (s *SearchParams) SetSignedRange(from *time.Time, to *time.Time) {
+	s.signedFrom = from
+	s.signedTo = to
+}
 
-	if len(params.resources) != 0 {
-		filters = append(filters, elastic.NewTermsQuery("metadata.resource", params.resources...))
-	}
+// SetCreatedRange limits the search to contracts created between from and
+// to, both inclusive. Either bound may be nil for an open-ended range.
+func (s *SearchParams) SetCreatedRange(from *time.Time, to *time.Time) {
+	s.createdFrom = from
+	s.createdTo = to
+}
 
-	if params.province != "" {
-		filters = append(filters, elastic.NewTermsQuery("metadata.provinces.province", params.province))
+// SetExclude removes contracts matching any of the comma-separated values
+// of a dimension (see dimensions), e.g. SetExclude("resource", "27,30").
+// Company and government names are taken as a single value, as in
+// NewSearchParams.
+func (s *SearchParams) SetExclude(dimension string, values string) {
+	if values == "" {
+		return
 	}
 
-	if len(params.district) > 0 {
-		filters = append(filters, elastic.NewTermsQuery("metadata.provinces.district", params.district...))
+	parts := strings.Split(values, ",")
+	if dimension == "company" || dimension == "government" {
+		parts = splitNames(values)
 	}
 
-	if len(params.documentTypes) > 0 {
-		for _, documentType := range params.documentTypes {
-			filters = append(filters, elastic.NewTermsQuery("metadata.document_type.keyword", correction.DocumentTypesReverse[documentType.(string)]))
-		}
+	if s.excludes == nil {
+		s.excludes = map[string][]interface{}{}
 	}
-
-	if len(params.contractTypes) > 0 {
-		for _, t := range params.contractTypes {
-			filters = append(filters, elastic.NewTermsQuery("metadata.contract_type.keyword", correction.ContractTypesReverse[t.(string)]))
+	for _, part := range parts {
+		if dimension == "year" || dimension == "district" {
+			num, err := strconv.Atoi(part)
+			if err != nil {
+				continue
+			}
+			s.excludes[dimension] = append(s.excludes[dimension], num)
+		} else if part = strings.TrimSpace(part); part != "" {
+			s.excludes[dimension] = append(s.excludes[dimension], part)
 		}
 	}
+}
 
-	if len(params.annotationCategory) > 0 {
-		for _, t := range params.annotationCategory {
-			phrases = append(phrases, elastic.NewMatchPhraseQuery("annotations_category", t))
+// splitNames splits a comma-separated list of company or government names.
+// A comma that is part of a name is written as \,.
+func splitNames(value string) []string {
+	var names []string
+	var name strings.Builder
+	runes := []rune(value)
+	for i := 0; i <= len(runes); i++ {
+		switch {
+		case i < len(runes) && runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == ',':
+			name.WriteRune(',')
+			i++
+		case i == len(runes) || runes[i] == ',':
+			if n := strings.TrimSpace(name.String()); n != "" {
+				names = append(names, n)
+			}
+			name.Reset()
+		default:
+			name.WriteRune(runes[i])
 		}
 	}
+	return names
+}
 
-	if len(params.governments) > 0 {
-		filters = append(filters, elastic.NewTermsQuery("metadata.government_entity.entity.keyword", params.governments))
-		// phrases = append(phrases, elastic.NewMatchQuery("metadata.government_entity.entity", params.governments).Operator("and"))
+// ParseFieldQuery moves the field-scoped parts of q, such as
+// `company:"Oyu Tolgoi"` or `-type:MoU`, into targeted clauses. The bare
+// terms stay in q and are searched as before.
+//
+// Returns:
+//   - error: ErrQuerySyntax with the known prefixes if q is malformed
+func (s *SearchParams) ParseFieldQuery() error {
+	clauses, rest, err := parseFieldQuery(s.q)
+	if err != nil {
+		return err
 	}
+	s.fieldClauses = clauses
+	s.q = rest
+	return nil
+}
 
-	if len(params.companies) > 0 {
-		filters = append(filters, elastic.NewTermsQuery("metadata.company_name.keyword", params.companies))
-		// phrases = append(phrases, elastic.NewMatchQuery("metadata.company_name", params.companies).Operator("and"))
-	}
+// SetDebug adds the Elasticsearch request body, a timing breakdown and
+// per-hit score explanations to the response. It is meant for admins only.
+func (s *SearchParams) SetDebug(debug bool) {
+	s.debug = debug
+}
 
-	fields := []string{
-		"metadata.contract_name",
-		"metadata.project_title",
-		"metadata.open_contracting_id",
-		"metadata.country_code",
-		"metadata.country_name",
-		"metadata.resource",
-		"metadata.resource_raw",
-		"metadata.language",
-		"metadata.company_name",
-		"metadata.type_of_contract",
-		// "metadata.corporate_grouping",
-		"metadata.show_pdf_text",
-		"metadata.category",
-		"metadata_string",
-		"pdf_text_string",
-	}
+// Debug reports whether the search runs in debug mode.
+func (s *SearchParams) Debug() bool {
+	return s.debug
+}
 
-	highlights := []string{
-		"pdf_text_string",
-		"metadata_string",
+// SetCursor resumes paging after the hit the cursor was issued for. It must
+// be called after SetSortBy and SetOrder, and the cursor has to come from a
+// search with the same sorting. The from offset is ignored while a cursor is set.
+func (s *SearchParams) SetCursor(value string) error {
+	c, err := decodeCursor(value)
+	if err != nil {
+		return err
 	}
 
-	var ftsQuery *elastic.SimpleQueryStringQuery
-	highlight := elastic.NewHighlight().PreTags("<strong>").PostTags("</strong>")
+	if c.SortBy != *s.sortBy || c.Asc != *s.order {
+		return fmt.Errorf("cursor was issued for a different sort_by or is_asc")
+	}
 
-	if params.q != "" {
-		ftsQuery = elastic.NewSimpleQueryStringQuery(params.q)
-		for _, field := range fields {
-			ftsQuery = ftsQuery.Field(field)
-		}
+	s.cursor = c
+	return nil
+}
 
-		ftsQuery = ftsQuery.DefaultOperator("AND")
+// filterQuery builds the bool query that selects the contracts of the
+// search: the full-text query, the field clauses of q, the excludes and the
+// given filters. Every endpoint that takes the /api/search parameters
+// selects its contracts with it.
+func (s *SearchParams) filterQuery(filters []elastic.Query) *elastic.BoolQuery {
+	boolQuery := elastic.NewBoolQuery()
 
-		for _, h := range highlights {
-			highlight = highlight.Field(h).FragmentSize(50).NumOfFragments(2)
+	for _, c := range s.fieldClauses {
+		if c.negated {
+			boolQuery = boolQuery.MustNot(s.fieldQuery(c))
+		} else {
+			boolQuery = boolQuery.Must(s.fieldQuery(c))
 		}
 	}
 
 	// considered as unnessasary
 	// boolQuery = boolQuery.MustNot(elastic.NewMatchQuery("pdf_text_string", "NotEnoughCredits"))
 
-	if len(phrases) > 0 {
-		boolQuery = boolQuery.Should(phrases...)
-	}
-
 	if len(filters) > 0 {
 		boolQuery = boolQuery.Filter(filters...)
 	}
 
-	src, err := boolQuery.Source()
-	if err != nil {
-		log.Fatalf("Error getting query source: %v", err)
+	if excludes := s.excludeFilters(); len(excludes) > 0 {
+		boolQuery = boolQuery.MustNot(excludes...)
 	}
 
-	jsonData, err := json.MarshalIndent(src, "", "  ")
+	if s.q != "" {
+		queryString := expandSynonyms(s.q)
+		if s.transliterate {
+			queryString = expandQuery(queryString)
+		}
+
+		boolQuery = boolQuery.Must(s.relevanceProfile().textQuery(queryString))
+	}
+
+	return boolQuery
+}
+
+// SearchV2 executes a comprehensive search query against Elasticsearch.
+// It builds a bool query with filters, performs full-text search if specified,
+// applies highlights, sorting, and pagination.
+//
+// Parameters:
+//   - params: SearchParams object containing all search criteria
+//
+// Returns:
+//   - *SearchResponse: Search results from Elasticsearch with optional facets
+//   - *error: Error if the search fails
+func SearchV2(params *SearchParams) (*SearchResponse, *error) {
+	started := time.Now()
+	timing := SearchTiming{}
+
+	client, err := appcontext.ElasticInstance.GetV5()
 	if err != nil {
-		log.Fatalf("Error marshalling query: %v", err)
+		panic(err)
 	}
 
-	fmt.Println("Generated Query:")
-	fmt.Println(string(jsonData))
+	groupedFilters := params.facetFilters()
+	filters := flattenFilters(groupedFilters, "")
 
-	index := os.Getenv("ELASTICSEARCH_SECONDARY")
-	docType := os.Getenv("ELASTICSEARCH_DOC_MASTER")
+	highlights := []string{
+		"pdf_text_string",
+		"metadata_string",
+	}
 
-	q := client.Search().
-		Index(index).
-		Type(docType)
+	highlight := elastic.NewHighlight().PreTags("<strong>").PostTags("</strong>")
 
-	if ftsQuery != nil {
-		boolQuery = boolQuery.Must(ftsQuery)
+	if params.q != "" || len(params.fieldClauses) > 0 {
+		for _, h := range highlights {
+			highlight = highlight.Field(h).FragmentSize(50).NumOfFragments(2)
+		}
+	}
+
+	// With facets the filters move to post_filter so that each facet
+	// aggregation can leave out its own dimension.
+	queryFilters := filters
+	if params.facets {
+		queryFilters = nil
 	}
+	boolQuery := params.filterQuery(queryFilters)
+
+	index := os.Getenv("ELASTICSEARCH_SECONDARY")
+	docType := os.Getenv("ELASTICSEARCH_DOC_MASTER")
+
+	q := elastic.NewSearchSource()
 
 	// if ftsQuery != nil {
 	// 	q = q.Query(ftsQuery)
@@ -347,26 +506,154 @@ func SearchV2(params *SearchParams) (*elastic.SearchResult, *error) {
 	// 	q = q.Query(boolQuery)
 	// }
 
-	q = q.Query(boolQuery)
+	// Only full-text hits are scored, so the profile's boosts are applied
+	// with them.
+	var query elastic.Query = boolQuery
+	if params.q != "" {
+		query = params.relevanceProfile().scoreQuery(boolQuery)
+	}
+
+	q = q.Query(query)
+
+	if params.q != "" {
+		for _, suggester := range spellingSuggesters(params.q) {
+			q = q.Suggester(suggester)
+		}
+	}
+
+	postFilters := []elastic.Query{}
+	if params.facets {
+		postFilters = append(postFilters, filters...)
+		for name, agg := range facetAggregations(groupedFilters) {
+			q = q.Aggregation(name, agg)
+		}
+	}
+	if len(postFilters) > 0 {
+		q = q.PostFilter(elastic.NewBoolQuery().Filter(postFilters...))
+	}
 
 	if highlight != nil {
 		q = q.Highlight(highlight)
 	}
 
 	if params.sortBy != nil {
-		q = q.Sort(*params.sortBy, *params.order)
+		q = q.Sort(*params.sortBy, *params.order).Sort(tiebreakerField, true)
+	}
+
+	// Grouped searches first read the IDs of all hits to form the groups,
+	// then fetch the documents of the requested page of groups.
+	var groups [][]string
+	var totalHits int64
+	var totalGroups int
+	var groupsCapped bool
+	switch {
+	case params.groupBy != "":
+		idSearch := client.Search().Index(index).Type(docType).Query(query)
+		if len(postFilters) > 0 {
+			idSearch = idSearch.PostFilter(elastic.NewBoolQuery().Filter(postFilters...))
+		}
+		if params.sortBy != nil {
+			idSearch = idSearch.Sort(*params.sortBy, *params.order).Sort(tiebreakerField, true)
+		}
+
+		groupingStarted := time.Now()
+		var allGroups [][]string
+		allGroups, totalHits, groupsCapped, err = groupHitIDs(idSearch, params.groupBy)
+		if err != nil {
+			err = fmt.Errorf("error grouping search: %v", err)
+			return nil, &err
+		}
+		timing.GroupingMs = milliseconds(time.Since(groupingStarted))
+
+		var ids []string
+		groups, ids = pageGroups(allGroups, params.from, params.size)
+		pageFilter := append(postFilters, elastic.NewIdsQuery(docType).Ids(ids...))
+		q = q.PostFilter(elastic.NewBoolQuery().Filter(pageFilter...)).From(0).Size(len(ids))
+		totalGroups = len(allGroups)
+	case params.cursor != nil:
+		q = q.SearchAfter(params.cursor.Values...).Size(params.size)
+	default:
+		q = q.From(params.from).Size(params.size)
 	}
 
-	q = q.From(params.from)
-	q = q.Size(params.size)
+	if params.debug {
+		q = q.Explain(true).Profile(true)
+	}
 
-	result, err := q.Pretty(true).Do(context.Background())
+	body, err := q.Source()
+	if err != nil {
+		err = fmt.Errorf("error building search: %v", err)
+		return nil, &err
+	}
+	slog.Debug("search request", "index", index, "type", docType, "body", body)
+	timing.BuildMs = milliseconds(time.Since(started)) - timing.GroupingMs
+
+	requested := time.Now()
+	result, err := client.Search().
+		Index(index).
+		Type(docType).
+		SearchSource(q).
+		Pretty(true).
+		Do(context.Background())
+	timing.RoundTripMs = milliseconds(time.Since(requested))
 
 	if err != nil {
 		if elasticErr, ok := err.(*elastic.Error); ok {
 			fmt.Printf("Elastic error details: %v\n", elasticErr.Details)
 		}
-		log.Fatalf("Error executing search: %s", err)
+		err = fmt.Errorf("error executing search: %v", err)
+		return nil, &err
+	}
+
+	response := &SearchResponse{SearchResult: result}
+	if params.groupBy != "" {
+		response.Groups = buildGroups(params.groupBy, groups, result.Hits.Hits)
+		response.TotalGroups = &totalGroups
+		response.GroupsCapped = groupsCapped
+
+		tops := []*elastic.SearchHit{}
+		for _, group := range response.Groups {
+			tops = append(tops, group.TopHit)
+		}
+		result.Hits.Hits = tops
+		result.Hits.TotalHits = totalHits
+	}
+
+	if params.facets {
+		response.Facets = parseFacets(result.Aggregations)
+	}
+
+	if params.q != "" {
+		response.DidYouMean = didYouMean(params.q, result.Suggest)
+
+		if response.DidYouMean != "" && result.Hits.TotalHits == 0 && params.autoCorrect {
+			corrected := *params
+			corrected.q = response.DidYouMean
+			corrected.autoCorrect = false
+
+			correctedResponse, err := SearchV2(&corrected)
+			if *err != nil {
+				return nil, err
+			}
+			correctedResponse.DidYouMean = response.DidYouMean
+			correctedResponse.OriginalQuery = params.q
+			return correctedResponse, err
+		}
 	}
-	return result, &err
+
+	if hits := result.Hits.Hits; params.groupBy == "" && params.size > 0 && len(hits) == params.size && len(hits[len(hits)-1].Sort) > 0 {
+		response.NextCursor = encodeCursor(*params.sortBy, *params.order, hits[len(hits)-1].Sort)
+	}
+
+	if params.debug {
+		timing.ElasticsearchMs = float64(result.TookInMillis)
+		timing.TotalMs = milliseconds(time.Since(started))
+		response.Debug = &SearchDebug{Request: body, Timing: timing, Profile: result.Profile}
+	}
+
+	return response, &err
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
 }
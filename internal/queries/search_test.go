@@ -0,0 +1,92 @@
+package queries
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+func TestLegacyArgumentsFromValues(t *testing.T) {
+	args, err := LegacyArgumentsFromValues(url.Values{"q": {"gold"}, "per_page": {"10"}, "from": {"20"}, "annotated": {"1"}, "group": {"metadata,text"}})
+	if err != nil {
+		t.Fatalf("LegacyArgumentsFromValues() error = %v", err)
+	}
+	if *args.Q != "gold" || *args.PerPage != 10 || *args.From != 20 || !*args.Annotated || args.Year != nil {
+		t.Errorf("LegacyArgumentsFromValues() = %+v", args)
+	}
+
+	for _, values := range []url.Values{
+		{"per_page": {"0"}},
+		{"per_page": {"101"}},
+		{"from": {"-1"}},
+		{"from": {"9990"}},
+		{"sort_by": {"relevance"}},
+		{"order": {"up"}},
+		{"group": {"metadata,pdf"}},
+		{"annotated": {"maybe"}},
+	} {
+		if _, err := LegacyArgumentsFromValues(values); err == nil {
+			t.Errorf("LegacyArgumentsFromValues(%v) error = nil, want an error", values)
+		}
+	}
+}
+
+func TestLegacySearchSource(t *testing.T) {
+	args, err := LegacyArgumentsFromValues(url.Values{
+		"q":             {"gold"},
+		"group":         {"text"},
+		"district":      {"5"},
+		"resource":      {"Tin,41"},
+		"contract_type": {"Концессийн гэрээ"},
+		"language":      {"mn,en"},
+		"sort_by":       {"contract_name"},
+		"order":         {"asc"},
+	})
+	if err != nil {
+		t.Fatalf("LegacyArgumentsFromValues() error = %v", err)
+	}
+
+	src, err := legacySearchSource(args).Source()
+	if err != nil {
+		t.Fatalf("Source() error = %v", err)
+	}
+	body, _ := json.Marshal(src)
+
+	for _, want := range []string{
+		`"fields":["pdf_text_string"]`,
+		`"metadata.provinces.district":["5"]`,
+		`"metadata.resource":["76","98","41"]`,
+		`"metadata.contract_type.keyword":["Concession Agreement"]`,
+		`"metadata.language.keyword":["mn","en"]`,
+		`{"metadata.contract_name.keyword":{"order":"asc"}}`,
+		`"size":25`,
+		`"corporate_group":{"terms"`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("legacySearchSource() = %s, want it to contain %s", body, want)
+		}
+	}
+}
+
+func TestLegacyResult(t *testing.T) {
+	source := json.RawMessage(`{"metadata": {"contract_name": "Coal Supply", "signature_year": "2012", "resource": ["41"], "contract_type": "Concession Agreement", "is_ocr_reviewed": true}}`)
+	hit := &elastic.SearchHit{
+		Id:        "7",
+		Source:    &source,
+		Highlight: elastic.SearchHitHighlight{"pdf_text_string": {"<strong>gold</strong> mine", "of <strong>gold</strong>"}},
+	}
+
+	result := legacyResult(hit)
+	if result.ID != "7" || result.Name != "Coal Supply" || result.YearSigned != "2012" || !result.IsOcrReviewed {
+		t.Errorf("legacyResult() = %+v", result)
+	}
+	if strings.Join(result.Resource, ",") != "Алт" || strings.Join(result.ContractType, ",") != "Concession Agreement" {
+		t.Errorf("legacyResult() resource = %v, contract_type = %v", result.Resource, result.ContractType)
+	}
+	if result.Text != "<strong>gold</strong> mine ... of <strong>gold</strong>" || strings.Join(result.Group, ",") != "Text" {
+		t.Errorf("legacyResult() text = %q, group = %v", result.Text, result.Group)
+	}
+}
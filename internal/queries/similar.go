@@ -0,0 +1,267 @@
+package queries
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	appcontext "iltodgeree/api/internal/app_context"
+	"iltodgeree/api/internal/correction"
+	"os"
+	"strings"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// ErrContractNotFound is returned when the contract ID does not exist.
+var ErrContractNotFound = errors.New("contract not found")
+
+// similarBoosts weights the reasons two contracts are similar. Shared
+// metadata counts for more than similar text.
+var similarBoosts = map[string]float64{
+	"company":  3,
+	"project":  2,
+	"resource": 1.5,
+	"province": 1,
+	"text":     1,
+}
+
+// similarReasons lists the reasons in the order they are explained.
+var similarReasons = []string{"company", "project", "resource", "province", "text"}
+
+// SimilarityReason is one reason a contract was found similar, with the
+// values it shares with the original contract.
+type SimilarityReason struct {
+	Field  string   `json:"field"`
+	Values []string `json:"values,omitempty"`
+}
+
+// SimilarContract is a contract related to the one being read.
+type SimilarContract struct {
+	ID          string             `json:"id"`
+	Score       float64            `json:"score"`
+	Source      *json.RawMessage   `json:"_source"`
+	Reasons     []SimilarityReason `json:"reasons"`
+	Explanation string             `json:"explanation"`
+}
+
+// contractProfile holds the metadata similar contracts are matched on.
+type contractProfile struct {
+	companies []string
+	project   string
+	resources []string
+	provinces []string
+}
+
+// profileOf reads the matching metadata from a contract source.
+func profileOf(source *json.RawMessage) contractProfile {
+	var doc struct {
+		Metadata map[string]interface{} `json:"metadata"`
+	}
+	if source != nil {
+		_ = json.Unmarshal(*source, &doc)
+	}
+
+	p := contractProfile{
+		companies: stringValues(doc.Metadata["company_name"]),
+		resources: stringValues(doc.Metadata["resource"]),
+	}
+	if project := stringValues(doc.Metadata["project_title"]); len(project) > 0 {
+		p.project = project[0]
+	}
+	if provinces, ok := doc.Metadata["provinces"].([]interface{}); ok {
+		for _, province := range provinces {
+			if m, ok := province.(map[string]interface{}); ok {
+				for _, id := range stringValues(m["province"]) {
+					p.provinces = appendUnique(p.provinces, id)
+				}
+			}
+		}
+	}
+	return p
+}
+
+// stringValues flattens a string, number or array source value.
+func stringValues(value interface{}) []string {
+	var values []string
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			values = append(values, v)
+		}
+	case float64, json.Number:
+		values = append(values, fmt.Sprint(v))
+	case []interface{}:
+		for _, item := range v {
+			values = append(values, stringValues(item)...)
+		}
+	}
+	return values
+}
+
+// shared returns the values found in both lists.
+func shared(a []string, b []string) []string {
+	var both []string
+	for _, x := range a {
+		for _, y := range b {
+			if strings.EqualFold(x, y) {
+				both = appendUnique(both, x)
+			}
+		}
+	}
+	return both
+}
+
+// similarQuery builds the query for contracts similar to the one with the
+// given ID and profile. Each clause is named after its reason so the hits
+// report which ones matched.
+func similarQuery(index string, docType string, id string, p contractProfile) elastic.Query {
+	should := []elastic.Query{
+		elastic.NewMoreLikeThisQuery().
+			Field("pdf_text_string", "metadata_string").
+			LikeItems(elastic.NewMoreLikeThisQueryItem().Index(index).Type(docType).Id(id)).
+			MinTermFreq(2).
+			MaxQueryTerms(50).
+			MinimumShouldMatch("30%").
+			Boost(similarBoosts["text"]).
+			QueryName("text"),
+	}
+
+	if len(p.companies) > 0 {
+		should = append(should, elastic.NewTermsQuery("metadata.company_name.keyword", interfaces(p.companies)...).Boost(similarBoosts["company"]).QueryName("company"))
+	}
+	if p.project != "" {
+		should = append(should, elastic.NewTermQuery("metadata.project_title.keyword", p.project).Boost(similarBoosts["project"]).QueryName("project"))
+	}
+	if len(p.resources) > 0 {
+		should = append(should, elastic.NewTermsQuery("metadata.resource", interfaces(p.resources)...).Boost(similarBoosts["resource"]).QueryName("resource"))
+	}
+	if len(p.provinces) > 0 {
+		should = append(should, elastic.NewTermsQuery("metadata.provinces.province", interfaces(p.provinces)...).Boost(similarBoosts["province"]).QueryName("province"))
+	}
+
+	return elastic.NewBoolQuery().
+		Should(should...).
+		MinimumNumberShouldMatch(1).
+		MustNot(elastic.NewIdsQuery(docType).Ids(id))
+}
+
+// explainSimilarity lists the matched reasons of a hit with the values it
+// shares with the original contract, and sums them up in a sentence.
+func explainSimilarity(original contractProfile, hit contractProfile, matched []string) ([]SimilarityReason, string) {
+	isMatched := map[string]bool{}
+	for _, name := range matched {
+		isMatched[name] = true
+	}
+
+	reasons := []SimilarityReason{}
+	var parts []string
+	for _, name := range similarReasons {
+		if !isMatched[name] {
+			continue
+		}
+
+		var values []string
+		switch name {
+		case "company":
+			values = shared(original.companies, hit.companies)
+			parts = append(parts, "same company ("+strings.Join(values, ", ")+")")
+		case "project":
+			values = []string{original.project}
+			parts = append(parts, "same project ("+original.project+")")
+		case "resource":
+			for _, id := range shared(original.resources, hit.resources) {
+				if label, ok := correction.Resources[id]; ok {
+					values = append(values, label)
+				} else {
+					values = append(values, id)
+				}
+			}
+			parts = append(parts, "shares resources ("+strings.Join(values, ", ")+")")
+		case "province":
+			values = shared(original.provinces, hit.provinces)
+			parts = append(parts, "same province")
+		case "text":
+			parts = append(parts, "similar text")
+		}
+		reasons = append(reasons, SimilarityReason{Field: name, Values: values})
+	}
+
+	explanation := strings.Join(parts, ", ")
+	if explanation != "" {
+		explanation = strings.ToUpper(explanation[:1]) + explanation[1:]
+	}
+	return reasons, explanation
+}
+
+// SimilarContracts finds contracts related to the given one by text
+// (more-like-this over pdf_text_string and metadata_string) and by shared
+// company, project, resources and province.
+//
+// Parameters:
+//   - id: The contract to find similar contracts for
+//   - size: Maximum number of similar contracts to return
+//
+// Returns:
+//   - []SimilarContract: Similar contracts with the reasons they matched
+//   - error: ErrContractNotFound if the contract does not exist, or the search error
+func SimilarContracts(id string, size int) ([]SimilarContract, error) {
+	client, err := appcontext.ElasticInstance.GetV5()
+	if err != nil {
+		panic(err)
+	}
+
+	index := os.Getenv("ELASTICSEARCH_SECONDARY")
+	docType := os.Getenv("ELASTICSEARCH_DOC_MASTER")
+	metadataOnly := elastic.NewFetchSourceContext(true).Include("metadata")
+
+	contract, err := client.Get().
+		Index(index).
+		Type(docType).
+		Id(id).
+		FetchSourceContext(metadataOnly).
+		Do(context.Background())
+	if elastic.IsNotFound(err) || (err == nil && !contract.Found) {
+		return nil, ErrContractNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching document: %v", err)
+	}
+	original := profileOf(contract.Source)
+
+	result, err := client.Search().
+		Index(index).
+		Type(docType).
+		Query(similarQuery(index, docType, id, original)).
+		FetchSourceContext(metadataOnly).
+		Size(size).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search: %v", err)
+	}
+
+	similar := []SimilarContract{}
+	for _, hit := range result.Hits.Hits {
+		reasons, explanation := explainSimilarity(original, profileOf(hit.Source), hit.MatchedQueries)
+		contract := SimilarContract{
+			ID:          hit.Id,
+			Source:      hit.Source,
+			Reasons:     reasons,
+			Explanation: explanation,
+		}
+		if hit.Score != nil {
+			contract.Score = *hit.Score
+		}
+		similar = append(similar, contract)
+	}
+
+	return similar, nil
+}
+
+func interfaces(values []string) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		result = append(result, v)
+	}
+	return result
+}
@@ -0,0 +1,54 @@
+package queries
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExplainSimilarity(t *testing.T) {
+	raw := func(s string) *json.RawMessage {
+		m := json.RawMessage(s)
+		return &m
+	}
+	original := profileOf(raw(`{"metadata": {"company_name": ["Oyu Tolgoi LLC", "Rio Tinto"], "project_title": "Oyu Tolgoi", "resource": ["30", "41"], "provinces": [{"province": "12", "district": "3"}]}}`))
+	hit := profileOf(raw(`{"metadata": {"company_name": ["Oyu Tolgoi LLC"], "project_title": "Oyu Tolgoi", "resource": ["41"], "provinces": [{"province": 12}]}}`))
+
+	reasons, explanation := explainSimilarity(original, hit, []string{"text", "resource", "company", "province"})
+
+	want := []SimilarityReason{
+		{Field: "company", Values: []string{"Oyu Tolgoi LLC"}},
+		{Field: "resource", Values: []string{"Алт"}},
+		{Field: "province", Values: []string{"12"}},
+		{Field: "text"},
+	}
+	if len(reasons) != len(want) {
+		t.Fatalf("explainSimilarity() = %v, want %v", reasons, want)
+	}
+	for i := range want {
+		if reasons[i].Field != want[i].Field || strings.Join(reasons[i].Values, ",") != strings.Join(want[i].Values, ",") {
+			t.Errorf("reason %d = %v, want %v", i, reasons[i], want[i])
+		}
+	}
+	if explanation != "Same company (Oyu Tolgoi LLC), shares resources (Алт), same province, similar text" {
+		t.Errorf("explanation = %q", explanation)
+	}
+}
+
+func TestSimilarQuery(t *testing.T) {
+	p := contractProfile{companies: []string{"Oyu Tolgoi LLC"}, resources: []string{"30"}}
+	src, err := similarQuery("contracts", "master", "7", p).Source()
+	if err != nil {
+		t.Fatalf("Source() error = %v", err)
+	}
+	body, _ := json.Marshal(src)
+
+	for _, want := range []string{`"more_like_this"`, `"_name":"company"`, `"boost":3`, `"_name":"resource"`, `"ids":{"type":"master","values":["7"]}`} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("similarQuery() = %s, want it to contain %s", body, want)
+		}
+	}
+	if strings.Contains(string(body), `"_name":"project"`) {
+		t.Errorf("similarQuery() = %s, want no project clause without a project", body)
+	}
+}
@@ -0,0 +1,173 @@
+package queries
+
+import (
+	"iltodgeree/api/internal/correction"
+	"math"
+	"strings"
+	"unicode"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// spellingFields lists the fields term suggesters draw corrections from,
+// keyed by suggester name.
+var spellingFields = map[string]string{
+	"spelling_pdf_text": "pdf_text_string",
+	"spelling_metadata": "metadata_string",
+}
+
+// resourceVocabulary holds the lower-cased words of the known resource names.
+var resourceVocabulary = buildVocabulary(correction.Resources)
+
+// token is a word of the query with its position in runes.
+type token struct {
+	text   string
+	offset int
+	length int
+}
+
+func buildVocabulary(names correction.Map) map[string]bool {
+	vocabulary := map[string]bool{}
+	for _, name := range names {
+		for _, t := range queryTokens(name) {
+			if len([]rune(t.text)) >= 3 {
+				vocabulary[strings.ToLower(t.text)] = true
+			}
+		}
+	}
+	return vocabulary
+}
+
+// spellingSuggesters builds the term suggesters for q.
+func spellingSuggesters(q string) []elastic.Suggester {
+	suggesters := []elastic.Suggester{}
+	for name, field := range spellingFields {
+		suggesters = append(suggesters, elastic.NewTermSuggester(name).Text(q).Field(field).Size(5))
+	}
+	return suggesters
+}
+
+// queryTokens splits q into its letter and digit runs.
+func queryTokens(q string) []token {
+	var tokens []token
+	start := -1
+	runes := []rune(q)
+
+	for i, r := range runes {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{text: string(runes[start:i]), offset: start, length: i - start})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{text: string(runes[start:]), offset: start, length: len(runes) - start})
+	}
+
+	return tokens
+}
+
+// didYouMean rewrites the misspelled words of q using the suggester results
+// and the resource vocabulary. It returns "" when nothing was corrected.
+// Query syntax around the words (quotes, +, -) is kept as typed.
+func didYouMean(q string, suggest elastic.SearchSuggest) string {
+	options := map[int][]elastic.SearchSuggestionOption{}
+	for name := range spellingFields {
+		for _, s := range suggest[name] {
+			options[s.Offset] = append(options[s.Offset], s.Options...)
+		}
+	}
+
+	runes := []rune(q)
+	var b strings.Builder
+	last := 0
+	changed := false
+
+	for _, t := range queryTokens(q) {
+		best := correctWord(t.text, options[t.offset])
+		if best == "" {
+			continue
+		}
+		b.WriteString(string(runes[last:t.offset]))
+		b.WriteString(best)
+		last = t.offset + t.length
+		changed = true
+	}
+
+	if !changed {
+		return ""
+	}
+
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
+
+// correctWord picks the closest correction of word, or "" to keep it.
+// Resource names are trusted over index terms but only one edit away, so
+// that inflected forms are left alone.
+func correctWord(word string, options []elastic.SearchSuggestionOption) string {
+	lower := strings.ToLower(word)
+	length := len([]rune(lower))
+	if length < 3 || resourceVocabulary[lower] || strings.IndexFunc(lower, unicode.IsDigit) >= 0 {
+		return ""
+	}
+
+	maxEdits := 2
+	if length <= 4 {
+		maxEdits = 1
+	}
+
+	best := ""
+	bestDistance := maxEdits + 1
+	bestFreq := -1
+
+	if length >= 4 {
+		for known := range resourceVocabulary {
+			if d := levenshtein(lower, known); d == 1 && (d < bestDistance || known < best) {
+				best, bestDistance, bestFreq = known, d, math.MaxInt
+			}
+		}
+	}
+
+	for _, o := range options {
+		d := levenshtein(lower, o.Text)
+		if d == 0 || d > maxEdits {
+			continue
+		}
+		if d < bestDistance || (d == bestDistance && o.Freq > bestFreq) {
+			best, bestDistance, bestFreq = o.Text, d, o.Freq
+		}
+	}
+
+	return best
+}
+
+// levenshtein returns the edit distance between a and b in runes.
+func levenshtein(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,60 @@
+package queries
+
+import (
+	"testing"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+func TestDidYouMean(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       string
+		suggest elastic.SearchSuggest
+		want    string
+	}{
+		{
+			name: "resource vocabulary",
+			q:    "нүүрч гэрээ",
+			want: "нүүрс гэрээ",
+		},
+		{
+			name: "index term keeps query syntax",
+			q:    `"ашигт малтмал" -лицнз`,
+			suggest: elastic.SearchSuggest{
+				"spelling_pdf_text": {
+					{Text: "лицнз", Offset: 17, Length: 5, Options: []elastic.SearchSuggestionOption{
+						{Text: "лиценз", Freq: 40},
+						{Text: "линз", Freq: 2},
+					}},
+				},
+			},
+			want: `"ашигт малтмал" -лиценз`,
+		},
+		{
+			name: "more frequent option wins a tie",
+			q:    "oyu tolgoj",
+			suggest: elastic.SearchSuggest{
+				"spelling_metadata": {
+					{Text: "tolgoj", Offset: 4, Length: 6, Options: []elastic.SearchSuggestionOption{
+						{Text: "tolgod", Freq: 3},
+						{Text: "tolgoi", Freq: 120},
+					}},
+				},
+			},
+			want: "oyu tolgoi",
+		},
+		{
+			name: "known words are kept",
+			q:    "алт зэс",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := didYouMean(tt.q, tt.suggest); got != tt.want {
+				t.Errorf("didYouMean() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	appcontext "iltodgeree/api/internal/app_context"
+	"iltodgeree/api/internal/correction"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// suggestFields maps the field parameter of /api/suggest to the keyword
+// field whose values are valid filter values.
+var suggestFields = map[string]string{
+	"company":    "metadata.company_name.keyword",
+	"government": "metadata.government_entity.entity.keyword",
+	"project":    "metadata.project_title.keyword",
+	"resource":   "metadata.resource.keyword",
+}
+
+// ErrUnknownSuggestField is returned for a field /api/suggest does not support.
+var ErrUnknownSuggestField = errors.New("unknown field, expected company, government, project or resource")
+
+// suggestCandidates is how many matching terms are fetched before ranking.
+var suggestCandidates = 500
+
+// maxSuggestQuery limits the length of q so the include pattern stays small.
+var maxSuggestQuery = 50
+
+// Suggestion is a type-ahead match. Value is the exact filter value to use
+// with /api/search, Label is what to display.
+type Suggestion struct {
+	Value    string `json:"value"`
+	Label    string `json:"label"`
+	DocCount int64  `json:"doc_count"`
+	Match    string `json:"match"`
+}
+
+// Suggest returns filter values of a field matching q as a prefix or infix,
+// in either Cyrillic or Latin spelling. Prefix matches (of the whole value
+// or of one of its words) rank before infix matches, then by document count.
+//
+// Parameters:
+//   - field: One of company, government, project or resource
+//   - q: Text typed by the user
+//   - size: Maximum number of suggestions
+//
+// Returns:
+//   - []Suggestion: Ranked suggestions
+//   - error: Error if the field is unknown or the query fails
+func Suggest(field string, q string, size int) ([]Suggestion, error) {
+	esField, ok := suggestFields[field]
+	if !ok {
+		return nil, ErrUnknownSuggestField
+	}
+
+	if runes := []rune(q); len(runes) > maxSuggestQuery {
+		q = string(runes[:maxSuggestQuery])
+	}
+
+	variants := spellings(q)
+	var prefix, infix *regexp.Regexp
+	if len(variants) > 0 {
+		var patterns []string
+		for _, sp := range variants {
+			patterns = append(patterns, sp.goRegexp())
+		}
+		pattern := "(?:" + strings.Join(patterns, "|") + ")"
+		prefix = regexp.MustCompile(`(?:^|[\s"«(])` + pattern)
+		infix = regexp.MustCompile(pattern)
+	}
+
+	terms := elastic.NewTermsAggregation().Field(esField).Size(suggestCandidates)
+
+	labels := map[string]string{}
+	if field == "resource" {
+		var ids []interface{}
+		for id, name := range correction.Resources {
+			labels[id] = name
+			if infix == nil || infix.MatchString(name) || infix.MatchString(correction.ResourcesEnglish[id]) {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			return []Suggestion{}, nil
+		}
+		terms = terms.IncludeValues(ids...)
+	} else if len(variants) > 0 {
+		var patterns []string
+		for _, sp := range variants {
+			patterns = append(patterns, sp.lucene())
+		}
+		terms = terms.Include(".*(" + strings.Join(patterns, "|") + ").*")
+	}
+
+	client, err := appcontext.ElasticInstance.GetV5()
+	if err != nil {
+		return nil, err
+	}
+
+	index := os.Getenv("ELASTICSEARCH_SECONDARY")
+	docType := os.Getenv("ELASTICSEARCH_DOC_MASTER")
+
+	result, err := client.Search().
+		Index(index).
+		Type(docType).
+		Size(0).
+		Aggregation("suggestions", terms).
+		Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []Suggestion{}
+	agg, found := result.Aggregations.Terms("suggestions")
+	if !found {
+		return suggestions, nil
+	}
+
+	for _, bucket := range agg.Buckets {
+		value := fmt.Sprint(bucket.Key)
+		label := value
+		if l, ok := labels[value]; ok {
+			label = l
+		}
+
+		match := "prefix"
+		if prefix != nil && !prefix.MatchString(label) && !prefix.MatchString(correction.ResourcesEnglish[value]) {
+			match = "infix"
+		}
+
+		suggestions = append(suggestions, Suggestion{
+			Value:    value,
+			Label:    label,
+			DocCount: bucket.DocCount,
+			Match:    match,
+		})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Match != b.Match {
+			return a.Match == "prefix"
+		}
+		if a.DocCount != b.DocCount {
+			return a.DocCount > b.DocCount
+		}
+		return a.Label < b.Label
+	})
+
+	if len(suggestions) > size {
+		suggestions = suggestions[:size]
+	}
+
+	return suggestions, nil
+}
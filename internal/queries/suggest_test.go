@@ -0,0 +1,82 @@
+package queries
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	newFakeElastic(t, "testdata/company_contracts.json")
+
+	tests := []struct {
+		name  string
+		field string
+		q     string
+		size  int
+		want  string
+	}{
+		{name: "ranked by count then label", field: "company", q: "tolgoi", size: 10, want: "Oyu Tolgoi LLC:2:prefix,Erdenes Oyu Tolgoi LLC:1:prefix,Эрдэнэс Таван Толгой ХК:1:prefix"},
+		{name: "size", field: "company", q: "tolgoi", size: 1, want: "Oyu Tolgoi LLC:2:prefix"},
+		{name: "cyrillic input", field: "company", q: "Эрдэнэс", size: 10, want: "\"Эрдэнэс Монгол\" ХХК:1:prefix,Erdenes Oyu Tolgoi LLC:1:prefix,Монголын Эрдэнэс ХХК:1:prefix,Эрдэнэс Таван Толгой ХК:1:prefix"},
+		{name: "prefix of a later word", field: "government", q: "yaa", size: 10, want: "Сангийн яам:2:prefix,Уул уурхай, хүнд үйлдвэрийн яам:1:prefix"},
+		{name: "infix", field: "company", q: "golyn", size: 10, want: "Монголын Эрдэнэс ХХК:1:infix"},
+		{name: "no match", field: "company", q: "zzz", size: 10, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Suggest(tt.field, tt.q, tt.size)
+			if err != nil {
+				t.Fatalf("Suggest() error = %v", err)
+			}
+			var got []string
+			for _, s := range res {
+				got = append(got, s.Value+":"+strconv.FormatInt(s.DocCount, 10)+":"+s.Match)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("Suggest(%s, %q) = %v, want %s", tt.field, tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestPrefixBeforeInfix(t *testing.T) {
+	newFakeElastic(t, "testdata/suggest_contracts.json")
+
+	// "gol" starts Golomt but is inside Монголын, which has more contracts.
+	res, err := Suggest("company", "gol", 10)
+	if err != nil {
+		t.Fatalf("Suggest() error = %v", err)
+	}
+	var got []string
+	for _, s := range res {
+		got = append(got, s.Value+":"+strconv.FormatInt(s.DocCount, 10)+":"+s.Match)
+	}
+	want := "Golomt Mining LLC:1:prefix,Монголын Эрдэнэс ХХК:3:infix"
+	if strings.Join(got, ",") != want {
+		t.Errorf("Suggest(company, gol) = %v, want %s", got, want)
+	}
+}
+
+func TestSuggestResource(t *testing.T) {
+	f := newFakeElastic(t, "testdata/quality_contracts.json")
+
+	for _, q := range []string{"алт", "Gold"} {
+		res, err := Suggest("resource", q, 10)
+		if err != nil {
+			t.Fatalf("Suggest(resource, %q) error = %v", q, err)
+		}
+		if len(res) != 1 || res[0].Value != "41" || res[0].Label != "Алт" || res[0].DocCount != 2 || res[0].Match != "prefix" {
+			t.Errorf("Suggest(resource, %q) = %+v, want 41 Алт", q, res)
+		}
+	}
+
+	if _, ok := f.searches[0]["aggregations"].(map[string]interface{})["suggestions"].(map[string]interface{})["terms"].(map[string]interface{})["include"].([]interface{}); !ok {
+		t.Errorf("resource suggestions should include resource IDs, got %v", f.searches[0]["aggregations"])
+	}
+
+	if _, err := Suggest("country", "mn", 10); !errors.Is(err, ErrUnknownSuggestField) {
+		t.Errorf("Suggest(country) error = %v, want ErrUnknownSuggestField", err)
+	}
+}
@@ -0,0 +1,153 @@
+package queries
+
+import (
+	"iltodgeree/api/internal/correction"
+	"sort"
+	"strings"
+)
+
+// synonymStoplist holds generic values that would only add noise when
+// expanded ("other", placeholders used in testing).
+var synonymStoplist = map[string]bool{
+	"other":   true,
+	"бусад":   true,
+	"unknown": true,
+	"test":    true,
+	"test123": true,
+}
+
+// synonyms maps a normalized phrase to its translations. It is generated
+// from the bilingual correction maps.
+var synonyms, maxSynonymWords = buildSynonyms()
+
+// synonymPairs lists the English-Mongolian pairs the synonym set is built from.
+func synonymPairs() [][2]string {
+	var pairs [][2]string
+	for _, m := range []correction.Map{correction.ContractTypes, correction.ContractTypesReverse, correction.DocumentTypes, correction.DocumentTypesReverse} {
+		for k, v := range m {
+			pairs = append(pairs, [2]string{k, v})
+		}
+	}
+	for id, mn := range correction.Resources {
+		if en, ok := correction.ResourcesEnglish[id]; ok {
+			pairs = append(pairs, [2]string{en, mn})
+		}
+	}
+	return pairs
+}
+
+// normalizePhrase lower-cases the words of s and joins them with single
+// spaces, dropping punctuation.
+func normalizePhrase(s string) string {
+	var words []string
+	for _, t := range queryTokens(s) {
+		words = append(words, strings.ToLower(t.text))
+	}
+	return strings.Join(words, " ")
+}
+
+// buildSynonyms merges the pairs into groups of equivalent phrases and
+// returns, for every phrase, the other members of its group.
+func buildSynonyms() (map[string][]string, int) {
+	groupOf := map[string]int{}
+	groups := map[int]map[string]bool{}
+	next := 0
+
+	for _, pair := range synonymPairs() {
+		a, b := normalizePhrase(pair[0]), normalizePhrase(pair[1])
+		if a == "" || b == "" || a == b || synonymStoplist[a] || synonymStoplist[b] {
+			continue
+		}
+
+		ga, okA := groupOf[a]
+		gb, okB := groupOf[b]
+		switch {
+		case okA && okB && ga != gb:
+			for phrase := range groups[gb] {
+				groups[ga][phrase] = true
+				groupOf[phrase] = ga
+			}
+			delete(groups, gb)
+		case okA:
+			groups[ga][b] = true
+			groupOf[b] = ga
+		case okB:
+			groups[gb][a] = true
+			groupOf[a] = gb
+		default:
+			groups[next] = map[string]bool{a: true, b: true}
+			groupOf[a], groupOf[b] = next, next
+			next++
+		}
+	}
+
+	result := map[string][]string{}
+	maxWords := 0
+	for phrase, g := range groupOf {
+		var others []string
+		for other := range groups[g] {
+			if other != phrase {
+				others = append(others, other)
+			}
+		}
+		sort.Strings(others)
+		result[phrase] = others
+		maxWords = max(maxWords, len(strings.Fields(phrase)))
+	}
+
+	return result, maxWords
+}
+
+// expandSynonyms adds the translations of every known phrase in q as
+// alternatives, e.g. `gold -coal` becomes `(gold|"алт") -(coal|"нүүрс")`.
+// The longest phrase wins and quoted phrases are kept as typed.
+func expandSynonyms(q string) string {
+	runes := []rune(q)
+	tokens := queryTokens(q)
+	quoted := quotedRunes(runes)
+
+	var b strings.Builder
+	last := 0
+
+	for i := 0; i < len(tokens); {
+		matched := 0
+		for n := min(maxSynonymWords, len(tokens)-i); n >= 1; n-- {
+			words := make([]string, n)
+			inQuote := false
+			for j, t := range tokens[i : i+n] {
+				words[j] = strings.ToLower(t.text)
+				inQuote = inQuote || quoted[t.offset]
+			}
+			others, ok := synonyms[strings.Join(words, " ")]
+			if !ok || inQuote {
+				continue
+			}
+
+			start := tokens[i].offset
+			end := tokens[i+n-1].offset + tokens[i+n-1].length
+			original := string(runes[start:end])
+			if n > 1 {
+				original = "(" + original + ")"
+			}
+
+			alternatives := []string{original}
+			for _, other := range others {
+				alternatives = append(alternatives, `"`+other+`"`)
+			}
+
+			b.WriteString(string(runes[last:start]))
+			b.WriteString("(" + strings.Join(alternatives, "|") + ")")
+			last = end
+			matched = n
+			break
+		}
+
+		if matched == 0 {
+			matched = 1
+		}
+		i += matched
+	}
+
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
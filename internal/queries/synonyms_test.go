@@ -0,0 +1,37 @@
+package queries
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandSynonyms(t *testing.T) {
+	tests := []struct {
+		name     string
+		q        string
+		contains []string
+	}{
+		{name: "English resource", q: "gold", contains: []string{"(gold|", `"алт"`}},
+		{name: "Mongolian resource", q: "Алт", contains: []string{"(Алт|", `"gold"`}},
+		{name: "multi-word contract type", q: "Concession Agreement", contains: []string{"((Concession Agreement)|", `"концессийн гэрээ"`}},
+		{name: "negation stays outside the group", q: "gold -coal", contains: []string{" -(coal|", `"нүүрс"`}},
+		{name: "quoted phrase is kept", q: `"gold mine"`, contains: []string{`"gold mine"`}},
+		{name: "unknown word is kept", q: "tolgoi", contains: []string{"tolgoi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := expandSynonyms(tt.q)
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("expandSynonyms(%q) = %q, want it to contain %q", tt.q, got, want)
+				}
+			}
+		})
+	}
+
+	for _, q := range []string{`"gold mine"`, "tolgoi", "other"} {
+		if got := expandSynonyms(q); got != q {
+			t.Errorf("expandSynonyms(%q) = %q, want it unchanged", q, got)
+		}
+	}
+}
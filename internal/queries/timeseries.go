@@ -0,0 +1,293 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	appcontext "iltodgeree/api/internal/app_context"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// ErrInvalidStatsParameter is returned for a statistics parameter outside
+// its allowed values.
+var ErrInvalidStatsParameter = errors.New("invalid stats parameter")
+
+// timeseriesFields maps the field parameter of /api/stats/timeseries to the
+// date field the contracts are counted on.
+var timeseriesFields = map[string]string{
+	"signature_date": "metadata.signature_date",
+	"created_at":     "created_at",
+}
+
+// maxTimeseriesIntervals caps the intervals of a series, so that a wide
+// date range or a stray date in the index cannot fill millions of zeros.
+var maxTimeseriesIntervals = 3000
+
+// timeseriesSplits lists the dimensions a time series can be split by.
+var timeseriesSplits = []string{"resource", "contract_type", "province"}
+
+// TimePoint is the count of one interval, ready to chart. X is the
+// interval as 2015, 2015-Q1 or 2015-01.
+type TimePoint struct {
+	X string `json:"x"`
+	Y int64  `json:"y"`
+}
+
+// TimeSeries is the counts of one split value, or of all contracts when
+// the series is not split.
+type TimeSeries struct {
+	Key   string      `json:"key"`
+	Label *Label      `json:"label,omitempty"`
+	Total int64       `json:"total"`
+	Data  []TimePoint `json:"data"`
+}
+
+// TimeseriesResult is the response of /api/stats/timeseries.
+type TimeseriesResult struct {
+	Field    string       `json:"field"`
+	Interval string       `json:"interval"`
+	Split    string       `json:"split,omitempty"`
+	Series   []TimeSeries `json:"series"`
+}
+
+// Timeseries counts the contracts matching the search parameters per month,
+// quarter or year. Intervals without contracts between the first and the
+// last one, or the date range of the field when one is given, are filled
+// with zeros. With a split there is one series per value; a contract with
+// several resources or provinces counts in each of them.
+//
+// Parameters:
+//   - params: Search parameters selecting the contracts, as for /api/search
+//   - field: signature_date or created_at
+//   - interval: month, quarter or year
+//   - split: Empty, resource, contract_type or province
+//
+// Returns:
+//   - *TimeseriesResult: The series, largest total first
+//   - error: ErrInvalidStatsParameter for an unknown field, interval or split or more than maxTimeseriesIntervals intervals, or the search error
+func Timeseries(params *SearchParams, field string, interval string, split string) (*TimeseriesResult, error) {
+	histogram, err := timeseriesHistogram(field, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	var splitField string
+	if split != "" {
+		splitField = dimensionField(split, timeseriesSplits)
+		if splitField == "" {
+			return nil, fmt.Errorf("%w: split must be resource, contract_type or province", ErrInvalidStatsParameter)
+		}
+	}
+
+	var agg elastic.Aggregation = histogram
+	if splitField != "" {
+		agg = elastic.NewTermsAggregation().Field(splitField).Size(10000).SubAggregation("histogram", histogram)
+	}
+
+	client, err := appcontext.ElasticInstance.GetV5()
+	if err != nil {
+		panic(err)
+	}
+
+	result, err := client.Search().
+		Index(os.Getenv("ELASTICSEARCH_SECONDARY")).
+		Type(os.Getenv("ELASTICSEARCH_DOC_MASTER")).
+		Query(params.filterQuery(flattenFilters(params.facetFilters(), ""))).
+		Size(0).
+		Aggregation("timeseries", agg).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search: %v", err)
+	}
+
+	from, to := params.signedFrom, params.signedTo
+	if field == "created_at" {
+		from, to = params.createdFrom, params.createdTo
+	}
+
+	series, err := parseTimeseries(result.Aggregations, split, interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TimeseriesResult{Field: field, Interval: interval, Split: split, Series: series}, nil
+}
+
+// timeseriesHistogram builds the date histogram of the field per interval.
+func timeseriesHistogram(field string, interval string) (*elastic.DateHistogramAggregation, error) {
+	esField, ok := timeseriesFields[field]
+	if !ok {
+		return nil, fmt.Errorf("%w: field must be signature_date or created_at", ErrInvalidStatsParameter)
+	}
+	if interval != "month" && interval != "quarter" && interval != "year" {
+		return nil, fmt.Errorf("%w: interval must be month, quarter or year", ErrInvalidStatsParameter)
+	}
+
+	return elastic.NewDateHistogramAggregation().
+		Field(esField).
+		Interval(interval).
+		Format(dateLayoutES).
+		TimeZone("UTC").
+		MinDocCount(1), nil
+}
+
+// dateLayoutES is dateLayout in the Elasticsearch date format syntax.
+const dateLayoutES = "yyyy-MM-dd"
+
+// dimensionField returns the aggregation field of a dimension if it is one
+// of the allowed ones.
+func dimensionField(name string, allowed []string) string {
+	for _, a := range allowed {
+		if a != name {
+			continue
+		}
+		for _, d := range dimensions {
+			if d.name == name {
+				return d.field
+			}
+		}
+	}
+	return ""
+}
+
+// parseTimeseries reads the series from the aggregation result and fills
+// the missing intervals between from (or the first interval) and to (or the
+// last interval) with zeros. It returns ErrInvalidStatsParameter when that
+// is more than maxTimeseriesIntervals intervals.
+func parseTimeseries(aggs elastic.Aggregations, split string, interval string, from *time.Time, to *time.Time) ([]TimeSeries, error) {
+	type counts struct {
+		key    string
+		values map[time.Time]int64
+	}
+	var all []counts
+
+	read := func(histogram *elastic.AggregationBucketHistogramItems, key string) error {
+		c := counts{key: key, values: map[time.Time]int64{}}
+		for _, bucket := range histogram.Buckets {
+			if bucket.KeyAsString == nil {
+				continue
+			}
+			t, err := time.Parse(dateLayout, *bucket.KeyAsString)
+			if err != nil {
+				return fmt.Errorf("error reading time series bucket %q: %v", *bucket.KeyAsString, err)
+			}
+			c.values[intervalStart(t, interval)] += bucket.DocCount
+		}
+		all = append(all, c)
+		return nil
+	}
+
+	if split == "" {
+		histogram, found := aggs.DateHistogram("timeseries")
+		if found {
+			if err := read(histogram, "total"); err != nil {
+				return nil, err
+			}
+		}
+	} else if terms, found := aggs.Terms("timeseries"); found {
+		for _, bucket := range terms.Buckets {
+			histogram, found := bucket.Aggregations.DateHistogram("histogram")
+			if !found {
+				continue
+			}
+			if err := read(histogram, fmt.Sprint(bucket.Key)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var first, last time.Time
+	for _, c := range all {
+		for t := range c.values {
+			if first.IsZero() || t.Before(first) {
+				first = t
+			}
+			if last.IsZero() || t.After(last) {
+				last = t
+			}
+		}
+	}
+	if from != nil {
+		first = intervalStart(*from, interval)
+	}
+	if to != nil {
+		last = intervalStart(*to, interval)
+	}
+	if !first.IsZero() && intervalCount(first, last, interval) > maxTimeseriesIntervals {
+		return nil, fmt.Errorf("%w: %s to %s has more than %d intervals; narrow the date range or use a longer interval",
+			ErrInvalidStatsParameter, intervalLabel(first, interval), intervalLabel(last, interval), maxTimeseriesIntervals)
+	}
+
+	series := []TimeSeries{}
+	for _, c := range all {
+		key, label := c.key, &Label{Mn: "Нийт", En: "Total"}
+		if split != "" {
+			key, label = namedLabel(split, c.key)
+		}
+
+		s := TimeSeries{Key: key, Label: label, Data: []TimePoint{}}
+		if !first.IsZero() {
+			for t := first; !t.After(last); t = nextInterval(t, interval) {
+				s.Data = append(s.Data, TimePoint{X: intervalLabel(t, interval), Y: c.values[t]})
+				s.Total += c.values[t]
+			}
+		}
+		series = append(series, s)
+	}
+
+	sort.SliceStable(series, func(i, j int) bool {
+		return series[i].Total > series[j].Total
+	})
+	return series, nil
+}
+
+// intervalStart returns the first day of the interval t falls in.
+func intervalStart(t time.Time, interval string) time.Time {
+	month := t.Month()
+	switch interval {
+	case "quarter":
+		month = (month-1)/3*3 + 1
+	case "year":
+		month = time.January
+	}
+	return time.Date(t.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// nextInterval returns the start of the interval after the one starting at t.
+func nextInterval(t time.Time, interval string) time.Time {
+	switch interval {
+	case "quarter":
+		return t.AddDate(0, 3, 0)
+	case "year":
+		return t.AddDate(1, 0, 0)
+	}
+	return t.AddDate(0, 1, 0)
+}
+
+// intervalCount returns the number of intervals from the one starting at
+// first to the one starting at last.
+func intervalCount(first time.Time, last time.Time, interval string) int {
+	months := (last.Year()-first.Year())*12 + int(last.Month()-first.Month())
+	switch interval {
+	case "quarter":
+		return months/3 + 1
+	case "year":
+		return months/12 + 1
+	}
+	return months + 1
+}
+
+// intervalLabel formats the interval starting at t for charting.
+func intervalLabel(t time.Time, interval string) string {
+	switch interval {
+	case "quarter":
+		return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())+2)/3)
+	case "year":
+		return t.Format("2006")
+	}
+	return t.Format("2006-01")
+}
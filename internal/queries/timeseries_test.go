@@ -0,0 +1,127 @@
+package queries
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+func TestParseTimeseries(t *testing.T) {
+	var aggs elastic.Aggregations
+	err := json.Unmarshal([]byte(`{"timeseries": {"buckets": [
+		{"key": "27", "doc_count": 3, "histogram": {"buckets": [
+			{"key_as_string": "2015-01-01", "key": 1420070400000, "doc_count": 2},
+			{"key_as_string": "2015-07-01", "key": 1435708800000, "doc_count": 1}
+		]}},
+		{"key": "41", "doc_count": 4, "histogram": {"buckets": [
+			{"key_as_string": "2015-04-01", "key": 1427846400000, "doc_count": 4}
+		]}}
+	]}}`), &aggs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	series, err := parseTimeseries(aggs, "resource", "quarter", nil, nil)
+	if err != nil {
+		t.Fatalf("parseTimeseries() error = %v", err)
+	}
+
+	if len(series) != 2 || series[0].Key != "41" || series[0].Total != 4 || series[1].Key != "27" || series[1].Total != 3 {
+		t.Fatalf("parseTimeseries() = %+v, want 41 then 27", series)
+	}
+	if series[0].Label.Mn != "Алт" {
+		t.Errorf("label = %+v, want Алт", series[0].Label)
+	}
+
+	want := []TimePoint{{"2015-Q1", 2}, {"2015-Q2", 0}, {"2015-Q3", 1}}
+	if len(series[1].Data) != len(want) {
+		t.Fatalf("data = %v, want %v", series[1].Data, want)
+	}
+	for i := range want {
+		if series[1].Data[i] != want[i] {
+			t.Errorf("data[%d] = %v, want %v", i, series[1].Data[i], want[i])
+		}
+	}
+	if series[0].Data[0] != (TimePoint{"2015-Q1", 0}) || series[0].Data[1] != (TimePoint{"2015-Q2", 4}) {
+		t.Errorf("data = %v, want every series over the same quarters", series[0].Data)
+	}
+}
+
+func TestParseTimeseriesRange(t *testing.T) {
+	var aggs elastic.Aggregations
+	err := json.Unmarshal([]byte(`{"timeseries": {"buckets": [
+		{"key_as_string": "2015-02-01", "key": 1422748800000, "doc_count": 5}
+	]}}`), &aggs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Date(2014, 12, 15, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2015, 3, 2, 0, 0, 0, 0, time.UTC)
+	series, err := parseTimeseries(aggs, "", "month", &from, &to)
+	if err != nil {
+		t.Fatalf("parseTimeseries() error = %v", err)
+	}
+
+	var got []string
+	for _, p := range series[0].Data {
+		got = append(got, p.X)
+	}
+	if len(series) != 1 || series[0].Key != "total" || len(got) != 4 || got[0] != "2014-12" || got[3] != "2015-03" || series[0].Data[2].Y != 5 {
+		t.Errorf("parseTimeseries() = %+v, want 2014-12 to 2015-03 with 5 in 2015-02", series)
+	}
+}
+
+func TestParseTimeseriesTooManyIntervals(t *testing.T) {
+	var aggs elastic.Aggregations
+	err := json.Unmarshal([]byte(`{"timeseries": {"buckets": [
+		{"key_as_string": "1015-02-01", "key": -30134246400000, "doc_count": 1},
+		{"key_as_string": "2015-02-01", "key": 1422748800000, "doc_count": 5}
+	]}}`), &aggs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parseTimeseries(aggs, "", "month", nil, nil); !errors.Is(err, ErrInvalidStatsParameter) {
+		t.Errorf("stray date: error = %v, want ErrInvalidStatsParameter", err)
+	}
+
+	from := time.Date(1800, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2100, 12, 31, 0, 0, 0, 0, time.UTC)
+	if _, err := parseTimeseries(aggs, "", "month", &from, &to); !errors.Is(err, ErrInvalidStatsParameter) {
+		t.Errorf("301 years of months: error = %v, want ErrInvalidStatsParameter", err)
+	}
+	series, err := parseTimeseries(aggs, "", "year", &from, &to)
+	if err != nil || len(series[0].Data) != 301 {
+		t.Errorf("301 years: error = %v, want 301 points", err)
+	}
+}
+
+func TestIntervalCount(t *testing.T) {
+	first := time.Date(2014, 12, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	for interval, want := range map[string]int{"month": 14, "quarter": 6, "year": 3} {
+		start := intervalStart(first, interval)
+		if got := intervalCount(start, intervalStart(last, interval), interval); got != want {
+			t.Errorf("intervalCount(%s) = %d, want %d", interval, got, want)
+		}
+	}
+}
+
+func TestTimeseriesParameters(t *testing.T) {
+	if _, err := timeseriesHistogram("signed", "month"); !errors.Is(err, ErrInvalidStatsParameter) {
+		t.Errorf("field signed: error = %v, want ErrInvalidStatsParameter", err)
+	}
+	if _, err := timeseriesHistogram("created_at", "week"); !errors.Is(err, ErrInvalidStatsParameter) {
+		t.Errorf("interval week: error = %v, want ErrInvalidStatsParameter", err)
+	}
+	if _, err := Timeseries(&SearchParams{}, "signature_date", "year", "company"); !errors.Is(err, ErrInvalidStatsParameter) {
+		t.Errorf("split company: error = %v, want ErrInvalidStatsParameter", err)
+	}
+	if dimensionField("province", timeseriesSplits) != "metadata.provinces.province.keyword" {
+		t.Errorf("dimensionField(province) = %q", dimensionField("province", timeseriesSplits))
+	}
+}
@@ -0,0 +1,147 @@
+package queries
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// cyrillicToLatin maps Mongolian Cyrillic letters to their usual Latin spelling.
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "ye", 'ё': "yo",
+	'ж': "j", 'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'ө': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ү': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh",
+	'щ': "sh", 'ъ': "", 'ы': "y", 'ь': "i", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// latinToCyrillic lists Latin spellings, digraphs first, with every Cyrillic
+// letter users commonly write that way ("u" is used for у, ү and ө alike).
+var latinToCyrillic = []struct {
+	latin    string
+	cyrillic string
+}{
+	{"kh", "х"}, {"ts", "ц"}, {"ch", "ч"}, {"sh", "шщ"},
+	{"ye", "е"}, {"yo", "ё"}, {"yu", "ю"}, {"ya", "я"},
+	{"a", "а"}, {"b", "б"}, {"c", "ц"}, {"d", "д"}, {"e", "эе"},
+	{"f", "ф"}, {"g", "г"}, {"h", "х"}, {"i", "ийьы"}, {"j", "жй"},
+	{"k", "к"}, {"l", "л"}, {"m", "м"}, {"n", "н"}, {"o", "оө"},
+	{"p", "п"}, {"q", "к"}, {"r", "р"}, {"s", "с"}, {"t", "т"},
+	{"u", "уүө"}, {"v", "в"}, {"w", "в"}, {"x", "х"}, {"y", "ыйи"},
+	{"z", "зж"},
+}
+
+// isCyrillic reports whether s contains any Cyrillic letter.
+func isCyrillic(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// toLatin lower-cases s and transliterates its Cyrillic letters to Latin.
+func toLatin(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if latin, ok := cyrillicToLatin[r]; ok {
+			b.WriteString(latin)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// spelling is a sequence of character classes; each position matches any
+// rune of its class.
+type spelling [][]rune
+
+// literalSpelling matches s case-insensitively.
+func literalSpelling(s string) spelling {
+	var sp spelling
+	for _, r := range strings.ToLower(s) {
+		sp = append(sp, withUpper([]rune{r}))
+	}
+	return sp
+}
+
+// cyrillicSpelling matches the Cyrillic words a Latin input may stand for.
+func cyrillicSpelling(s string) spelling {
+	var sp spelling
+	s = strings.ToLower(s)
+	for len(s) > 0 {
+		matched := false
+		for _, t := range latinToCyrillic {
+			if strings.HasPrefix(s, t.latin) {
+				sp = append(sp, withUpper([]rune(t.cyrillic)))
+				s = s[len(t.latin):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			r := []rune(s)[0]
+			sp = append(sp, withUpper([]rune{r}))
+			s = s[len(string(r)):]
+		}
+	}
+	return sp
+}
+
+// spellings returns the ways q may be written in the index: as typed and
+// transliterated into the other script.
+func spellings(q string) []spelling {
+	q = strings.Join(strings.Fields(q), " ")
+	if q == "" {
+		return nil
+	}
+
+	if isCyrillic(q) {
+		return []spelling{literalSpelling(q), literalSpelling(toLatin(q))}
+	}
+	return []spelling{literalSpelling(q), cyrillicSpelling(q)}
+}
+
+func withUpper(class []rune) []rune {
+	out := []rune{}
+	for _, r := range class {
+		out = append(out, r)
+		if u := unicode.ToUpper(r); u != r {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// lucene renders the spelling as a Lucene regular expression fragment, as
+// used by the include option of terms aggregations.
+func (sp spelling) lucene() string {
+	var b strings.Builder
+	for _, class := range sp {
+		if len(class) > 1 {
+			b.WriteString("[" + string(class) + "]")
+			continue
+		}
+		r := class[0]
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// goRegexp renders the spelling as a Go regular expression fragment.
+func (sp spelling) goRegexp() string {
+	var b strings.Builder
+	for _, class := range sp {
+		if len(class) > 1 {
+			b.WriteString("[" + string(class) + "]")
+			continue
+		}
+		b.WriteString(regexp.QuoteMeta(string(class)))
+	}
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package queries
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestToLatin(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Эрдэнэс Таван Толгой", "erdenes tavan tolgoi"},
+		{"Хөвсгөл", "khovsgol"},
+		{"Өмнөговь", "omnogovi"},
+		{"Цагаан Суварга", "tsagaan suvarga"},
+		{"Oyu Tolgoi LLC", "oyu tolgoi llc"},
+	}
+	for _, tt := range tests {
+		if got := toLatin(tt.in); got != tt.want {
+			t.Errorf("toLatin(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsCyrillic(t *testing.T) {
+	for in, want := range map[string]bool{"Алт": true, "gold": false, "ХХК LLC": true, "2015": false} {
+		if got := isCyrillic(in); got != want {
+			t.Errorf("isCyrillic(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestSpellings(t *testing.T) {
+	tests := []struct {
+		q     string
+		value string
+		want  bool
+	}{
+		{"erdenes", "Эрдэнэс Таван Толгой ХК", true},
+		{"erdenes", "ERDENES MONGOL", true},
+		{"tolgoi", "Таван Толгой", true},
+		{"khovsgol", "Хөвсгөл", true},
+		{"shivee", "Шивээ-Овоо", true},
+		{"Эрдэнэс", "Erdenes Oyu Tolgoi LLC", true},
+		{"эрдэнэс", "ЭРДЭНЭС", true},
+		{"oyu tol", "Oyu Tolgoi LLC", true},
+		{"oyu tol", "Oyu  Tolgoi", false},
+		{"gold", "Алт", false},
+	}
+	for _, tt := range tests {
+		var patterns []string
+		for _, sp := range spellings(tt.q) {
+			patterns = append(patterns, sp.goRegexp())
+		}
+		matched := false
+		for _, p := range patterns {
+			matched = matched || regexp.MustCompile(p).MatchString(tt.value)
+		}
+		if matched != tt.want {
+			t.Errorf("spellings(%q) match %q = %v, want %v (patterns %v)", tt.q, tt.value, matched, tt.want, patterns)
+		}
+	}
+
+	if spellings("  ") != nil {
+		t.Errorf("spellings of blank input = %v, want none", spellings("  "))
+	}
+}
+
+func TestSpellingRendering(t *testing.T) {
+	sp := literalSpelling("a.b c")
+	if got := sp.lucene(); got != `[aA]\.[bB]\ [cC]` {
+		t.Errorf("lucene() = %s", got)
+	}
+	if got := sp.goRegexp(); got != `[aA]\.[bB] [cC]` {
+		t.Errorf("goRegexp() = %s", got)
+	}
+	if got := cyrillicSpelling("kha").lucene(); got != "[хХ][аА]" {
+		t.Errorf("cyrillicSpelling(kha).lucene() = %s", got)
+	}
+}
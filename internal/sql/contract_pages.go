@@ -0,0 +1,53 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// contract_pages
+// id          | integer                        | not null
+// contract_id | integer                        | not null
+// page_no     | integer                        | not null
+// text        | text                           |
+// created_at  | timestamp(0) without time zone |
+// updated_at  | timestamp(0) without time zone |
+
+// ContractPage is the extracted text of one page of a contract PDF.
+type ContractPage struct {
+	PageNo int    `json:"page_no"`
+	Text   string `json:"text"`
+}
+
+// GetContractPages returns the pages of a contract in page order.
+//
+// Parameters:
+//   - contractID: The contract identifier
+//
+// Returns:
+//   - []ContractPage: Pages of the contract, empty if none were extracted
+//   - error: Error if the ID is not a number or the query fails
+func GetContractPages(contractID string) ([]ContractPage, error) {
+	_contractID, err := strconv.Atoi(contractID)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a instance of int", contractID)
+	}
+
+	rows, err := Pgsql.Query(context.Background(), `select page_no, coalesce(text, '') from contract_pages where contract_id = $1 order by page_no`, _contractID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pages := []ContractPage{}
+	for rows.Next() {
+		var page ContractPage
+		if err := rows.Scan(&page.PageNo, &page.Text); err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+
+	return pages, rows.Err()
+}
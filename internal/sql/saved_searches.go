@@ -0,0 +1,279 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// saved_searches
+// id              | integer                        | not null | nextval('saved_searches_id_seq'::regclass)
+// name            | character varying(255)         | not null |
+// query           | text                           | not null | raw /api/search query string
+// email           | character varying(255)         |          | alert recipient, optional
+// last_created_at | timestamp(0) with time zone    | not null | newest contract created_at already seen
+// created_at      | timestamp(0) with time zone    | not null |
+
+// saved_search_matches
+// id                  | integer                        | not null | nextval('saved_search_matches_id_seq'::regclass)
+// saved_search_id     | integer                        | not null | references saved_searches(id)
+// contract_id         | character varying(255)         | not null |
+// contract_name       | text                           | not null |
+// contract_created_at | timestamp(0) with time zone    | not null |
+// found_at            | timestamp(0) with time zone    | not null |
+// notified_at         | timestamp(0) with time zone    |          |
+
+// SavedSearch is a named /api/search query that is re-run periodically.
+type SavedSearch struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	Query         string    `json:"query"`
+	Email         *string   `json:"email"`
+	LastCreatedAt time.Time `json:"last_created_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SavedSearchMatch is a contract that newly matched a saved search.
+type SavedSearchMatch struct {
+	ID                int        `json:"id"`
+	SavedSearchID     int        `json:"saved_search_id"`
+	ContractID        string     `json:"contract_id"`
+	ContractName      string     `json:"contract_name"`
+	ContractCreatedAt time.Time  `json:"contract_created_at"`
+	FoundAt           time.Time  `json:"found_at"`
+	NotifiedAt        *time.Time `json:"notified_at"`
+}
+
+// EnsureSavedSearchTables creates the saved search tables if they are
+// missing. The timestamps are stored with their time zone because they are
+// compared with the UTC created_at of the index. Tables created without
+// time zones are converted once: contract times were written in UTC, the
+// now() defaults in the server's time zone.
+func EnsureSavedSearchTables() error {
+	query := `
+		create table if not exists saved_searches (
+			id serial primary key,
+			name varchar(255) not null,
+			query text not null,
+			email varchar(255),
+			last_created_at timestamptz(0) not null default now(),
+			created_at timestamptz(0) not null default now()
+		);
+		create table if not exists saved_search_matches (
+			id serial primary key,
+			saved_search_id integer not null references saved_searches(id) on delete cascade,
+			contract_id varchar(255) not null,
+			contract_name text not null,
+			contract_created_at timestamptz(0) not null,
+			found_at timestamptz(0) not null default now(),
+			notified_at timestamptz(0),
+			unique (saved_search_id, contract_id)
+		);
+		do $$
+		begin
+			if exists (select 1 from information_schema.columns
+				where table_schema = current_schema() and table_name = 'saved_search_matches'
+				and column_name = 'found_at'
+				and data_type = 'timestamp without time zone') then
+				alter table saved_searches
+					alter column last_created_at type timestamptz(0) using last_created_at at time zone 'UTC',
+					alter column created_at type timestamptz(0);
+				alter table saved_search_matches
+					alter column contract_created_at type timestamptz(0) using contract_created_at at time zone 'UTC',
+					alter column found_at type timestamptz(0),
+					alter column notified_at type timestamptz(0);
+			end if;
+		end
+		$$;`
+
+	_, err := Pgsql.Exec(context.Background(), query)
+	return err
+}
+
+// CreateSavedSearch stores a new saved search. Only contracts created after
+// this moment are reported as new matches.
+//
+// Parameters:
+//   - name: Display name of the search
+//   - query: Raw /api/search query string
+//   - email: Optional alert recipient
+//
+// Returns:
+//   - *SavedSearch: The stored search
+//   - error: Error if the insert fails
+func CreateSavedSearch(name string, query string, email *string) (*SavedSearch, error) {
+	var search SavedSearch
+	err := Pgsql.QueryRow(context.Background(),
+		`insert into saved_searches (name, query, email) values ($1, $2, $3)
+		returning id, name, query, email, last_created_at, created_at`,
+		name, query, email,
+	).Scan(&search.ID, &search.Name, &search.Query, &search.Email, &search.LastCreatedAt, &search.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &search, nil
+}
+
+// GetSavedSearches retrieves all saved searches ordered by name.
+func GetSavedSearches() ([]SavedSearch, error) {
+	rows, err := Pgsql.Query(context.Background(),
+		`select id, name, query, email, last_created_at, created_at from saved_searches order by name asc`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := []SavedSearch{}
+	for rows.Next() {
+		var search SavedSearch
+		if err := rows.Scan(&search.ID, &search.Name, &search.Query, &search.Email, &search.LastCreatedAt, &search.CreatedAt); err != nil {
+			return nil, err
+		}
+		searches = append(searches, search)
+	}
+
+	return searches, rows.Err()
+}
+
+// DeleteSavedSearch removes a saved search together with its matches.
+func DeleteSavedSearch(id string) error {
+	_id, err := strconv.Atoi(id)
+	if err != nil {
+		return fmt.Errorf("%s is not a instance of int", id)
+	}
+
+	_, err = Pgsql.Exec(context.Background(), `delete from saved_searches where id = $1`, _id)
+	return err
+}
+
+// GetSavedSearchMatches retrieves the recorded new matches of a saved search,
+// newest first.
+//
+// Parameters:
+//   - id: Saved search ID
+//   - since: Optional lower bound on found_at
+//
+// Returns:
+//   - []SavedSearchMatch: Recorded matches
+//   - error: Error if query fails
+func GetSavedSearchMatches(id string, since *time.Time) ([]SavedSearchMatch, error) {
+	_id, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a instance of int", id)
+	}
+
+	params := []any{_id}
+	query := `select id, saved_search_id, contract_id, contract_name, contract_created_at, found_at, notified_at
+		from saved_search_matches where saved_search_id = $1`
+	if since != nil {
+		query += ` and found_at >= $2`
+		params = append(params, *since)
+	}
+	query += ` order by found_at desc, contract_created_at desc`
+
+	rows, err := Pgsql.Query(context.Background(), query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []SavedSearchMatch{}
+	for rows.Next() {
+		var match SavedSearchMatch
+		if err := rows.Scan(&match.ID, &match.SavedSearchID, &match.ContractID, &match.ContractName, &match.ContractCreatedAt, &match.FoundAt, &match.NotifiedAt); err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+
+	return matches, rows.Err()
+}
+
+// GetUnnotifiedSavedSearchMatches retrieves the matches of a saved search
+// whose alert has not been sent, oldest first.
+func GetUnnotifiedSavedSearchMatches(searchID int) ([]SavedSearchMatch, error) {
+	rows, err := Pgsql.Query(context.Background(),
+		`select id, saved_search_id, contract_id, contract_name, contract_created_at, found_at, notified_at
+		from saved_search_matches where saved_search_id = $1 and notified_at is null
+		order by found_at asc, contract_created_at asc`, searchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []SavedSearchMatch{}
+	for rows.Next() {
+		var match SavedSearchMatch
+		if err := rows.Scan(&match.ID, &match.SavedSearchID, &match.ContractID, &match.ContractName, &match.ContractCreatedAt, &match.FoundAt, &match.NotifiedAt); err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+
+	return matches, rows.Err()
+}
+
+// AddSavedSearchMatches records new matches of a saved search and moves its
+// last_created_at forward. Contracts that were already recorded are skipped.
+//
+// Parameters:
+//   - searchID: Saved search ID
+//   - matches: Candidate matches
+//   - lastCreatedAt: Newest contract created_at seen in this run
+//
+// Returns:
+//   - []SavedSearchMatch: The matches that were actually inserted
+//   - error: Error if the transaction fails
+func AddSavedSearchMatches(searchID int, matches []SavedSearchMatch, lastCreatedAt time.Time) ([]SavedSearchMatch, error) {
+	ctx := context.Background()
+	tx, err := Pgsql.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	inserted := []SavedSearchMatch{}
+	for _, match := range matches {
+		rows, err := tx.Query(ctx,
+			`insert into saved_search_matches (saved_search_id, contract_id, contract_name, contract_created_at)
+			values ($1, $2, $3, $4)
+			on conflict (saved_search_id, contract_id) do nothing
+			returning id, found_at`,
+			searchID, match.ContractID, match.ContractName, match.ContractCreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			match.SavedSearchID = searchID
+			if err := rows.Scan(&match.ID, &match.FoundAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			inserted = append(inserted, match)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(ctx,
+		`update saved_searches set last_created_at = greatest(last_created_at, $2) where id = $1`,
+		searchID, lastCreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return inserted, tx.Commit(ctx)
+}
+
+// MarkSavedSearchMatchesNotified stamps notified_at on the given matches.
+func MarkSavedSearchMatchesNotified(ids []int) error {
+	_, err := Pgsql.Exec(context.Background(),
+		`update saved_search_matches set notified_at = now() where id = any($1)`, ids)
+	return err
+}
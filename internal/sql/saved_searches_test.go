@@ -0,0 +1,136 @@
+package sql
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSavedSearchMatches(t *testing.T) {
+	useTestDB(t)
+	if err := EnsureSavedSearchTables(); err != nil {
+		t.Fatal(err)
+	}
+	// A second run leaves converted tables alone.
+	if err := EnsureSavedSearchTables(); err != nil {
+		t.Fatal(err)
+	}
+
+	email := "analyst@example.mn"
+	search, err := CreateSavedSearch("Алт", "resource=41", &email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	second := first.Add(time.Hour)
+	matches := []SavedSearchMatch{
+		{ContractID: "11", ContractName: "A", ContractCreatedAt: first},
+		{ContractID: "12", ContractName: "B", ContractCreatedAt: second},
+	}
+	inserted, err := AddSavedSearchMatches(search.ID, matches, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inserted) != 2 {
+		t.Fatalf("inserted %d matches, want 2", len(inserted))
+	}
+
+	// Already recorded contracts are skipped and last_created_at never moves
+	// back.
+	inserted, err = AddSavedSearchMatches(search.ID, matches[:1], first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inserted) != 0 {
+		t.Errorf("re-inserted %d matches, want 0", len(inserted))
+	}
+
+	searches, err := GetSavedSearches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(searches) != 1 || !searches[0].LastCreatedAt.Equal(second) {
+		t.Errorf("saved searches = %+v, want last_created_at %v", searches, second)
+	}
+
+	unsent, err := GetUnnotifiedSavedSearchMatches(search.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unsent) != 2 || unsent[0].ContractID != "11" || !unsent[0].ContractCreatedAt.Equal(first) {
+		t.Fatalf("unsent matches = %+v, want 11 created at %v first", unsent, first)
+	}
+
+	if err := MarkSavedSearchMatchesNotified([]int{unsent[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	unsent, err = GetUnnotifiedSavedSearchMatches(search.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unsent) != 1 || unsent[0].ContractID != "12" {
+		t.Errorf("unsent matches = %+v, want only 12", unsent)
+	}
+
+	all, err := GetSavedSearchMatches(strconv.Itoa(search.ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Errorf("matches = %+v, want 2", all)
+	}
+
+	if err := DeleteSavedSearch(strconv.Itoa(search.ID)); err != nil {
+		t.Fatal(err)
+	}
+	all, err = GetSavedSearchMatches(strconv.Itoa(search.ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 0 {
+		t.Errorf("matches after delete = %+v, want none", all)
+	}
+}
+
+func TestEnsureSavedSearchTablesConvertsTimestamps(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+	_, err := Pgsql.Exec(ctx, `
+		create table saved_searches (
+			id serial primary key,
+			name varchar(255) not null,
+			query text not null,
+			email varchar(255),
+			last_created_at timestamp(0) not null default now(),
+			created_at timestamp(0) not null default now()
+		);
+		create table saved_search_matches (
+			id serial primary key,
+			saved_search_id integer not null references saved_searches(id) on delete cascade,
+			contract_id varchar(255) not null,
+			contract_name text not null,
+			contract_created_at timestamp(0) not null,
+			found_at timestamp(0) not null default now(),
+			notified_at timestamp(0),
+			unique (saved_search_id, contract_id)
+		);
+		insert into saved_searches (name, query, last_created_at) values ('Алт', 'resource=41', '2026-01-02 03:04:05');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EnsureSavedSearchTables(); err != nil {
+		t.Fatal(err)
+	}
+
+	searches, err := GetSavedSearches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if len(searches) != 1 || !searches[0].LastCreatedAt.Equal(want) {
+		t.Errorf("saved searches = %+v, want last_created_at %v", searches, want)
+	}
+}
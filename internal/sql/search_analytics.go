@@ -0,0 +1,317 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrSearchNotFound is returned for a click on a search that was not logged.
+var ErrSearchNotFound = errors.New("search not found")
+
+// search_log
+// id           | bigint                   | not null | nextval('search_log_id_seq'::regclass)
+// query        | text                     | not null | normalized q, '' when only filtering
+// filters      | jsonb                    | not null | normalized filters, {"resource": ["27"], ...}
+// hit_count    | bigint                   | not null |
+// result_from  | integer                  | not null | offset of the first returned hit, -1 after a cursor
+// result_count | integer                  | not null | number of hits returned
+// latency_ms   | double precision         | not null |
+// created_at   | timestamp with time zone | not null |
+
+// search_clicks
+// id         | bigint                   | not null | nextval('search_clicks_id_seq'::regclass)
+// search_id  | bigint                   | not null | references search_log(id)
+// contract_id| character varying(255)   | not null |
+// position   | integer                  | not null | 1-based rank of the clicked hit
+// created_at | timestamp with time zone | not null |
+// unique (search_id, contract_id)
+
+// SearchLogEntry is one recorded /api/search call.
+type SearchLogEntry struct {
+	Query       string
+	Filters     map[string][]string
+	HitCount    int64
+	ResultFrom  int
+	ResultCount int
+	LatencyMs   float64
+}
+
+// QueryReportRow is a query with how often it was searched.
+type QueryReportRow struct {
+	Query        string  `json:"query"`
+	Searches     int64   `json:"searches"`
+	AvgHits      float64 `json:"avg_hits"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+// FilterValueCount is how often a filter value was used.
+type FilterValueCount struct {
+	Value    string `json:"value"`
+	Searches int64  `json:"searches"`
+}
+
+// FilterReportRow is a filter with how often it and its values were used.
+type FilterReportRow struct {
+	Filter   string             `json:"filter"`
+	Searches int64              `json:"searches"`
+	Values   []FilterValueCount `json:"values"`
+}
+
+// ClickReportRow is the click-through rate of a result position.
+type ClickReportRow struct {
+	Position    int     `json:"position"`
+	Impressions int64   `json:"impressions"`
+	Clicks      int64   `json:"clicks"`
+	CTR         float64 `json:"ctr"`
+}
+
+// EnsureSearchAnalyticsTables creates the search analytics tables if they
+// are missing. Clicks are unique per search and contract; duplicates logged
+// before that was enforced are removed once, keeping the first click.
+func EnsureSearchAnalyticsTables() error {
+	query := `
+		create table if not exists search_log (
+			id bigserial primary key,
+			query text not null default '',
+			filters jsonb not null default '{}',
+			hit_count bigint not null,
+			result_from integer not null,
+			result_count integer not null,
+			latency_ms double precision not null,
+			created_at timestamptz not null default now()
+		);
+		create index if not exists search_log_created_at_idx on search_log (created_at);
+		create table if not exists search_clicks (
+			id bigserial primary key,
+			search_id bigint not null references search_log(id) on delete cascade,
+			contract_id varchar(255) not null,
+			position integer not null,
+			created_at timestamptz not null default now()
+		);
+		create index if not exists search_clicks_search_id_idx on search_clicks (search_id);
+		do $$
+		begin
+			if to_regclass('search_clicks_search_contract_idx') is null then
+				delete from search_clicks a using search_clicks b
+				where a.search_id = b.search_id and a.contract_id = b.contract_id and a.id > b.id;
+				create unique index search_clicks_search_contract_idx on search_clicks (search_id, contract_id);
+			end if;
+		end
+		$$;`
+
+	_, err := Pgsql.Exec(context.Background(), query)
+	return err
+}
+
+// LogSearch records a search.
+//
+// Parameters:
+//   - entry: The normalized search with its outcome
+//
+// Returns:
+//   - int64: ID of the recorded search, to be sent back with clicks
+//   - error: Error if the insert fails
+func LogSearch(entry SearchLogEntry) (int64, error) {
+	filters, err := json.Marshal(entry.Filters)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = Pgsql.QueryRow(context.Background(),
+		`insert into search_log (query, filters, hit_count, result_from, result_count, latency_ms)
+		values ($1, $2, $3, $4, $5, $6) returning id`,
+		entry.Query, filters, entry.HitCount, entry.ResultFrom, entry.ResultCount, entry.LatencyMs,
+	).Scan(&id)
+	return id, err
+}
+
+// LogSearchClick records a click on a search result. Only the first click
+// on a contract counts for a search; repeated clicks are ignored.
+//
+// Parameters:
+//   - searchID: ID returned by LogSearch
+//   - contractID: The clicked contract
+//   - position: 1-based rank of the clicked hit
+//
+// Returns:
+//   - error: ErrSearchNotFound if the search was not logged, or the insert error
+func LogSearchClick(searchID int64, contractID string, position int) error {
+	var found bool
+	err := Pgsql.QueryRow(context.Background(),
+		`with search as (
+			select id from search_log where id = $1
+		), inserted as (
+			insert into search_clicks (search_id, contract_id, position)
+			select id, $2, $3 from search
+			on conflict (search_id, contract_id) do nothing
+		)
+		select exists (select 1 from search)`,
+		searchID, contractID, position,
+	).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrSearchNotFound
+	}
+	return nil
+}
+
+// GetTopQueries returns the most searched queries between from and to.
+//
+// Parameters:
+//   - from: Start of the range, inclusive
+//   - to: End of the range, exclusive
+//   - limit: Maximum number of rows
+//   - zeroResults: Only count searches that found nothing
+//
+// Returns:
+//   - []QueryReportRow: Queries ordered by number of searches
+//   - error: Error if the query fails
+func GetTopQueries(from time.Time, to time.Time, limit int, zeroResults bool) ([]QueryReportRow, error) {
+	rows, err := Pgsql.Query(context.Background(),
+		`select query, count(*), avg(hit_count)::float8, avg(latency_ms)::float8
+		from search_log
+		where query <> '' and created_at >= $1 and created_at < $2 and ($4 = false or hit_count = 0)
+		group by query
+		order by count(*) desc, query asc
+		limit $3`,
+		from, to, limit, zeroResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := []QueryReportRow{}
+	for rows.Next() {
+		var row QueryReportRow
+		if err := rows.Scan(&row.Query, &row.Searches, &row.AvgHits, &row.AvgLatencyMs); err != nil {
+			return nil, err
+		}
+		report = append(report, row)
+	}
+
+	return report, rows.Err()
+}
+
+// GetFilterUsage returns how often each filter was used between from and
+// to, with its most used values.
+//
+// Parameters:
+//   - from: Start of the range, inclusive
+//   - to: End of the range, exclusive
+//   - limit: Maximum number of values per filter
+//
+// Returns:
+//   - []FilterReportRow: Filters ordered by number of searches
+//   - error: Error if the query fails
+func GetFilterUsage(from time.Time, to time.Time, limit int) ([]FilterReportRow, error) {
+	rows, err := Pgsql.Query(context.Background(),
+		`select f.key, count(distinct l.id)
+		from search_log l, jsonb_each(l.filters) f
+		where l.created_at >= $1 and l.created_at < $2
+		group by f.key
+		order by count(distinct l.id) desc, f.key asc`,
+		from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := []FilterReportRow{}
+	index := map[string]int{}
+	for rows.Next() {
+		row := FilterReportRow{Values: []FilterValueCount{}}
+		if err := rows.Scan(&row.Filter, &row.Searches); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[row.Filter] = len(report)
+		report = append(report, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = Pgsql.Query(context.Background(),
+		`select key, value, searches from (
+			select f.key, v.value, count(*) as searches,
+				row_number() over (partition by f.key order by count(*) desc, v.value asc) as rank
+			from search_log l, jsonb_each(l.filters) f, jsonb_array_elements_text(f.value) v
+			where l.created_at >= $1 and l.created_at < $2
+			group by f.key, v.value
+		) ranked
+		where rank <= $3
+		order by key, rank`,
+		from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var filter string
+		var value FilterValueCount
+		if err := rows.Scan(&filter, &value.Value, &value.Searches); err != nil {
+			return nil, err
+		}
+		if i, ok := index[filter]; ok {
+			report[i].Values = append(report[i].Values, value)
+		}
+	}
+
+	return report, rows.Err()
+}
+
+// GetClickThroughRates returns the click-through rate of the first result
+// positions for searches between from and to. A position counts as an
+// impression when a search returned a hit at that rank. Pages read with a
+// cursor have no known rank and are left out.
+//
+// Parameters:
+//   - from: Start of the range, inclusive
+//   - to: End of the range, exclusive
+//   - positions: Number of positions to report
+//
+// Returns:
+//   - []ClickReportRow: Rates ordered by position
+//   - error: Error if the query fails
+func GetClickThroughRates(from time.Time, to time.Time, positions int) ([]ClickReportRow, error) {
+	rows, err := Pgsql.Query(context.Background(),
+		`with impressions as (
+			select p as position, count(*) as impressions
+			from search_log l, generate_series(l.result_from + 1, l.result_from + l.result_count) p
+			where l.created_at >= $1 and l.created_at < $2 and l.result_from >= 0 and p <= $3
+			group by p
+		), clicks as (
+			select c.position, count(*) as clicks
+			from search_clicks c join search_log l on l.id = c.search_id
+			where l.created_at >= $1 and l.created_at < $2 and l.result_from >= 0
+			group by c.position
+		)
+		select i.position, i.impressions, coalesce(c.clicks, 0)
+		from impressions i left join clicks c using (position)
+		order by i.position`,
+		from, to, positions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := []ClickReportRow{}
+	for rows.Next() {
+		var row ClickReportRow
+		if err := rows.Scan(&row.Position, &row.Impressions, &row.Clicks); err != nil {
+			return nil, err
+		}
+		if row.Impressions > 0 {
+			row.CTR = float64(row.Clicks) / float64(row.Impressions)
+		}
+		report = append(report, row)
+	}
+
+	return report, rows.Err()
+}
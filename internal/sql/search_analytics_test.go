@@ -0,0 +1,129 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSearchAnalytics(t *testing.T) {
+	useTestDB(t)
+	if err := EnsureSearchAnalyticsTables(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []SearchLogEntry{
+		{Query: "gold", Filters: map[string][]string{"resource": {"41"}}, HitCount: 12, ResultFrom: 0, ResultCount: 3, LatencyMs: 10},
+		{Query: "gold", Filters: map[string][]string{"resource": {"41", "27"}}, HitCount: 8, ResultFrom: 0, ResultCount: 2, LatencyMs: 30},
+		{Query: "zzz", Filters: map[string][]string{}, HitCount: 0, ResultFrom: 0, ResultCount: 0, LatencyMs: 5},
+		{Query: "", Filters: map[string][]string{"year": {"2023"}}, HitCount: 4, ResultFrom: -1, ResultCount: 2, LatencyMs: 7},
+	}
+	var ids []int64
+	for _, entry := range entries {
+		id, err := LogSearch(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	// The second click on the same contract is ignored.
+	for _, click := range []struct {
+		id       int64
+		contract string
+		position int
+	}{{ids[0], "11", 1}, {ids[0], "11", 1}, {ids[0], "12", 2}, {ids[1], "11", 1}, {ids[3], "13", 1}} {
+		if err := LogSearchClick(click.id, click.contract, click.position); err != nil {
+			t.Fatalf("LogSearchClick(%d, %s) error = %v", click.id, click.contract, err)
+		}
+	}
+	if err := LogSearchClick(ids[3]+1000, "11", 1); !errors.Is(err, ErrSearchNotFound) {
+		t.Errorf("LogSearchClick() on an unknown search error = %v, want ErrSearchNotFound", err)
+	}
+
+	from, to := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	top, err := GetTopQueries(from, to, 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(top) != "[{gold 2 10 20} {zzz 1 0 5}]" {
+		t.Errorf("GetTopQueries() = %v", top)
+	}
+
+	zero, err := GetTopQueries(from, to, 10, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(zero) != "[{zzz 1 0 5}]" {
+		t.Errorf("GetTopQueries(zero results) = %v", zero)
+	}
+
+	filters, err := GetFilterUsage(from, to, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(filters) != "[{resource 2 [{41 2}]} {year 1 [{2023 1}]}]" {
+		t.Errorf("GetFilterUsage() = %v", filters)
+	}
+
+	// Position 3 was shown once and never clicked; the cursor page is left
+	// out.
+	ctr, err := GetClickThroughRates(from, to, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ctr) != "[{1 2 2 1} {2 2 1 0.5} {3 1 0 0}]" {
+		t.Errorf("GetClickThroughRates() = %v", ctr)
+	}
+
+	empty, err := GetTopQueries(to, to.Add(time.Hour), 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(empty) != 0 {
+		t.Errorf("GetTopQueries() outside the range = %v, want none", empty)
+	}
+}
+
+func TestEnsureSearchAnalyticsTablesRemovesDuplicateClicks(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+	_, err := Pgsql.Exec(ctx, `
+		create table search_log (
+			id bigserial primary key,
+			query text not null default '',
+			filters jsonb not null default '{}',
+			hit_count bigint not null,
+			result_from integer not null,
+			result_count integer not null,
+			latency_ms double precision not null,
+			created_at timestamptz not null default now()
+		);
+		create table search_clicks (
+			id bigserial primary key,
+			search_id bigint not null references search_log(id) on delete cascade,
+			contract_id varchar(255) not null,
+			position integer not null,
+			created_at timestamptz not null default now()
+		);
+		insert into search_log (query, hit_count, result_from, result_count, latency_ms) values ('gold', 3, 0, 3, 1);
+		insert into search_clicks (search_id, contract_id, position) values (1, '11', 1), (1, '11', 1), (1, '12', 2);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EnsureSearchAnalyticsTables(); err != nil {
+		t.Fatal(err)
+	}
+
+	var clicks int
+	if err := Pgsql.QueryRow(ctx, `select count(*) from search_clicks`).Scan(&clicks); err != nil {
+		t.Fatal(err)
+	}
+	if clicks != 2 {
+		t.Errorf("%d clicks left, want 2", clicks)
+	}
+}
@@ -0,0 +1,53 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// useTestDB points Pgsql at the database in PGSQL_TEST_URL, inside a schema
+// that is dropped after the test. The session runs in Asia/Ulaanbaatar so
+// that time zone mistakes show up. Tests are skipped without PGSQL_TEST_URL.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("PGSQL_TEST_URL")
+	if dsn == "" {
+		t.Skip("PGSQL_TEST_URL is not set")
+	}
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	admin, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.Exec(ctx, "create schema "+schema); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+	config.ConnConfig.RuntimeParams["timezone"] = "Asia/Ulaanbaatar"
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := Pgsql
+	Pgsql = pool
+	t.Cleanup(func() {
+		Pgsql = saved
+		pool.Close()
+		admin.Exec(ctx, "drop schema "+schema+" cascade")
+		admin.Close()
+	})
+}